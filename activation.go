@@ -0,0 +1,129 @@
+package svc
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/autokitteh/L"
+)
+
+// Names of listeners, as used by WithListener and LISTEN_FDNAMES.
+const (
+	HTTPListener  = "http"
+	GRPCListener  = "grpc"
+	PprofListener = "pprof"
+	AdminListener = "admin"
+)
+
+// Closes listeners with names other than the ones above, which would
+// otherwise never be used, and returns the rest.
+func dropUnknownListeners(l L.L, liss map[string][]net.Listener) map[string][]net.Listener {
+	known := make(map[string][]net.Listener, len(liss))
+
+	for n, ls := range liss {
+		switch n {
+		case HTTPListener, GRPCListener, PprofListener, AdminListener:
+			known[n] = ls
+			continue
+		}
+
+		l.Warn("closing inherited listeners with unknown name, see FileDescriptorName=", "name", n, "n", len(ls))
+
+		for _, lis := range ls {
+			lis.Close()
+		}
+	}
+
+	return known
+}
+
+// First file descriptor passed by the socket activation protocol.
+const listenFDsStart = 3
+
+// Returns listeners inherited from the parent process using the systemd
+// socket activation protocol (see sd_listen_fds(3)), by name.
+// Descriptors are named using LISTEN_FDNAMES, so socket units must specify
+// one of the listener names above using FileDescriptorName=, for example
+// "FileDescriptorName=grpc". Otherwise systemd names descriptors after the
+// socket unit. Unnamed descriptors, including ones named "unknown" which is
+// what systemd reports for descriptors without a name, are closed and
+// ignored. See also dropUnknownListeners.
+//
+// The protocol environment variables are unset, so they will not be
+// inherited by child processes.
+func inheritedListeners() (map[string][]net.Listener, error) {
+	pid, fds, names := os.Getenv("LISTEN_PID"), os.Getenv("LISTEN_FDS"), os.Getenv("LISTEN_FDNAMES")
+
+	os.Unsetenv("LISTEN_PID")
+	os.Unsetenv("LISTEN_FDS")
+	os.Unsetenv("LISTEN_FDNAMES")
+
+	return listenersFromFDs(pid, fds, names, listenFDsStart)
+}
+
+func listenersFromFDs(pidStr, fdsStr, namesStr string, start int) (map[string][]net.Listener, error) {
+	if pidStr == "" || fdsStr == "" {
+		return nil, nil
+	}
+
+	pid, err := strconv.Atoi(pidStr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid LISTEN_PID %q: %w", pidStr, err)
+	}
+
+	if pid != os.Getpid() {
+		// meant for another process.
+		return nil, nil
+	}
+
+	n, err := strconv.Atoi(fdsStr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid LISTEN_FDS %q: %w", fdsStr, err)
+	}
+
+	var names []string
+	if namesStr != "" {
+		names = strings.Split(namesStr, ":")
+	}
+
+	liss := make(map[string][]net.Listener, n)
+
+	for i := 0; i < n; i++ {
+		var name string
+		if i < len(names) {
+			name = names[i]
+		}
+
+		f := os.NewFile(uintptr(start+i), name)
+
+		if name == "" || name == "unknown" {
+			f.Close()
+			continue
+		}
+
+		// FileListener dups the descriptor, so f can be closed.
+		lis, err := net.FileListener(f)
+		f.Close()
+
+		if err != nil {
+			return nil, fmt.Errorf("fd %d (%s): %w", start+i, name, err)
+		}
+
+		liss[name] = append(liss[name], lis)
+	}
+
+	return liss, nil
+}
+
+// Returns listeners by name if were either provided or inherited. Otherwise,
+// listens on addrs.
+func (s *svc) listen(name string, addrs []string, unix unixSocketCfg) ([]net.Listener, error) {
+	if liss := s.listeners[name]; len(liss) != 0 {
+		return liss, nil
+	}
+
+	return listen(addrs, unix)
+}
@@ -0,0 +1,110 @@
+//go:build unit
+
+package svc
+
+import (
+	"bufio"
+	"net"
+	"os"
+	"os/exec"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/autokitteh/L"
+)
+
+// Runs in a child process started by TestInheritedListeners.
+func TestInheritedListenersChild(t *testing.T) {
+	if os.Getenv("SVC_TEST_CHILD") == "" {
+		t.Skip("not a child process")
+	}
+
+	liss, err := inheritedListeners()
+	require.NoError(t, err)
+	require.Len(t, liss[GRPCListener], 1)
+
+	// the unnamed descriptor is ignored.
+	assert.Len(t, liss, 1)
+
+	assert.Empty(t, os.Getenv("LISTEN_FDS"))
+
+	conn, err := liss[GRPCListener][0].Accept()
+	require.NoError(t, err)
+
+	_, err = conn.Write([]byte("meow\n"))
+	require.NoError(t, err)
+
+	conn.Close()
+}
+
+func TestInheritedListeners(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	defer lis.Close()
+
+	f, err := lis.(*net.TCPListener).File()
+	require.NoError(t, err)
+
+	unnamed, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	defer unnamed.Close()
+
+	unnamedF, err := unnamed.(*net.TCPListener).File()
+	require.NoError(t, err)
+
+	// LISTEN_PID must be the pid of the child, which is only known in the child.
+	cmd := exec.Command(
+		"sh", "-c", `LISTEN_PID=$$ exec "$0" -test.run '^TestInheritedListenersChild$'`,
+		os.Args[0],
+	)
+
+	cmd.Env = append(os.Environ(), "SVC_TEST_CHILD=1", "LISTEN_FDS=2", "LISTEN_FDNAMES="+GRPCListener+":unknown")
+	cmd.ExtraFiles = []*os.File{f, unnamedF}
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	require.NoError(t, cmd.Start())
+
+	f.Close()
+	unnamedF.Close()
+
+	conn, err := net.Dial("tcp", lis.Addr().String())
+	require.NoError(t, err)
+
+	line, err := bufio.NewReader(conn).ReadString('\n')
+	if assert.NoError(t, err) {
+		assert.Equal(t, "meow\n", line)
+	}
+
+	assert.NoError(t, cmd.Wait())
+}
+
+func TestListenersFromFDsOtherPID(t *testing.T) {
+	liss, err := listenersFromFDs("1", "1", GRPCListener, listenFDsStart)
+	assert.NoError(t, err)
+	assert.Nil(t, liss)
+}
+
+func TestDropUnknownListeners(t *testing.T) {
+	known, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	defer known.Close()
+
+	unknown, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	liss := dropUnknownListeners(L.Nop, map[string][]net.Listener{
+		GRPCListener:   {known},
+		"myapp.socket": {unknown},
+	})
+
+	assert.Equal(t, map[string][]net.Listener{GRPCListener: {known}}, liss)
+
+	_, err = unknown.Accept()
+	assert.ErrorIs(t, err, net.ErrClosed)
+}
@@ -0,0 +1,93 @@
+package svc
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/pprof"
+
+	"github.com/gorilla/mux"
+
+	"github.com/autokitteh/L"
+)
+
+// Router for the admin server, which serves operational endpoints such as
+// pprof, health, version and, if enabled, configuration. Components can add
+// their own debug pages to it. Provided to all components starting with the
+// init phase, even if the admin server is disabled.
+type AdminRouter struct{ *mux.Router }
+
+type adminDeps struct {
+	cfg      *SvcCfg
+	userCfgs []interface{}
+	health   *HealthRegistry
+	levels   *LogLevels // nil if log levels are not controllable.
+}
+
+func newAdminRouter(deps adminDeps) AdminRouter {
+	r := mux.NewRouter()
+
+	addPprofRoutes(r)
+
+	if deps.cfg.Health.Enabled {
+		r.Handle(deps.cfg.Health.LivenessPath, deps.health.handler(LivenessCheck))
+		r.Handle(deps.cfg.Health.ReadinessPath, deps.health.handler(ReadinessCheck))
+	}
+
+	r.HandleFunc("/version", func(w http.ResponseWriter, _ *http.Request) {
+		writeJSON(w, GetVersion())
+	})
+
+	if deps.cfg.Admin.Config {
+		r.HandleFunc("/config", func(w http.ResponseWriter, _ *http.Request) {
+			writeJSON(w, map[string]interface{}{"svc": deps.cfg.redacted(), "user": deps.userCfgs})
+		})
+	}
+
+	if deps.levels != nil {
+		r.Handle("/loglevel", deps.levels.handler())
+	}
+
+	return AdminRouter{Router: r}
+}
+
+// Serve pprof on r without using http.DefaultServeMux.
+func addPprofRoutes(r *mux.Router) {
+	r.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	r.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	r.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	r.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	r.PathPrefix("/debug/pprof/").HandlerFunc(pprof.Index)
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	_ = enc.Encode(v)
+}
+
+// Serve h for operational purposes. Errors are reported, but are not fatal.
+func startAdmin(l L.L, name string, h http.Handler, liss []net.Listener, sd *shutdown, errCh chan<- error) {
+	l.Debug("starting server")
+
+	srv := &http.Server{Handler: h}
+
+	for _, lis := range liss {
+		lis := lis
+
+		go func() {
+			if err := srv.Serve(lis); !errors.Is(err, http.ErrServerClosed) {
+				l.Error("exited", "err", err)
+				errCh <- fmt.Errorf("%s exited: %w", name, err)
+			}
+		}()
+
+		l.Debug("started", "addr", lis.Addr())
+	}
+
+	sd.add(name, srv.Shutdown)
+}
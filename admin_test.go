@@ -0,0 +1,52 @@
+//go:build unit
+
+package svc
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAdminConfig(t *testing.T) {
+	get := func(cfg *SvcCfg, userCfgs ...interface{}) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		newAdminRouter(adminDeps{cfg: cfg, userCfgs: userCfgs, health: &HealthRegistry{}}).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/config", nil))
+		return rec
+	}
+
+	cfg := &SvcCfg{}
+	cfg.Tracing.OTLPHeaders = map[string]string{"Authorization": "Bearer meow"}
+
+	assert.Equal(t, http.StatusNotFound, get(cfg).Code)
+
+	cfg.Admin.Config = true
+
+	type userCfg struct {
+		Name     string `json:"name"`
+		Password string `json:"-"`
+	}
+
+	rec := get(cfg, &userCfg{Name: "garfield", Password: "lasagna"})
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	assert.NotContains(t, rec.Body.String(), "meow")
+	assert.NotContains(t, rec.Body.String(), "lasagna")
+
+	var resp struct {
+		Svc  SvcCfg                   `json:"svc"`
+		User []map[string]interface{} `json:"user"`
+	}
+
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+
+	assert.Equal(t, map[string]string{"Authorization": "REDACTED"}, resp.Svc.Tracing.OTLPHeaders)
+	assert.Equal(t, []map[string]interface{}{{"name": "garfield"}}, resp.User)
+
+	// the configuration itself is not modified.
+	assert.Equal(t, "Bearer meow", cfg.Tracing.OTLPHeaders["Authorization"])
+}
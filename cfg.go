@@ -5,6 +5,7 @@ import (
 	"io/ioutil"
 	"os"
 	"text/tabwriter"
+	"time"
 
 	"github.com/ghodss/yaml"
 	"github.com/kelseyhightower/envconfig"
@@ -13,27 +14,175 @@ import (
 	"github.com/autokitteh/L/Z"
 )
 
+// Options for unix domain socket listeners.
+type unixSocketCfg struct {
+	Mode  string `envconfig:"MODE" json:"mode"`   // octal file mode, for example "0660".
+	Owner string `envconfig:"OWNER" json:"owner"` // user name or uid.
+	Group string `envconfig:"GROUP" json:"group"` // group name or gid.
+}
+
+// TLS is enabled if a certificate is specified. Certificates are reloaded
+// from disk when they change.
+type tlsCfg struct {
+	CertFile          string        `envconfig:"CERT_FILE" json:"cert_file"`
+	KeyFile           string        `envconfig:"KEY_FILE" json:"key_file"`
+	MinVersion        string        `envconfig:"MIN_VERSION" default:"1.2" json:"min_version"`         // 1.0, 1.1, 1.2 or 1.3.
+	CipherPolicy      string        `envconfig:"CIPHER_POLICY" default:"default" json:"cipher_policy"` // default, intermediate or modern.
+	ClientCAFile      string        `envconfig:"CLIENT_CA_FILE" json:"client_ca_file"`                 // verify client certificates, if given.
+	RequireClientCert bool          `envconfig:"REQUIRE_CLIENT_CERT" json:"require_client_cert"`
+	ReloadInterval    time.Duration `envconfig:"RELOAD_INTERVAL" default:"30s" json:"reload_interval"`
+}
+
+func (c tlsCfg) Enabled() bool { return c.CertFile != "" }
+
 type httpCfg struct {
-	Enabled              bool     `envconfig:"ENABLED" default:"true" json:"enabled"`
-	Port                 int      `envconfig:"PORT" default:"20000" json:"port"`
-	CORS                 bool     `envconfig:"CORS" default:"false" json:"cors"`
-	CORSAllowedOrigins   []string `envconfig:"CORS_ALLOWED_ORIGINS" json:"cors_allowed_origins"`
-	CORSAllowCredentials bool     `envconfig:"CORS_ALLOW_CREDENTIALS" default:"false" json:"cors_allow_credentails"`
-	AccessLogInfoLevel   bool     `envconfig:"ACCESS_LOG_INFO" default:"false" json:"access_log_info"`
+	Enabled              bool          `envconfig:"ENABLED" default:"true" json:"enabled"`
+	Host                 string        `envconfig:"HOST" json:"host"`
+	Port                 int           `envconfig:"PORT" default:"20000" json:"port"`
+	Addrs                []string      `envconfig:"ADDRS" json:"addrs"` // if specified, overrides host and port. unix:// addresses are unix sockets.
+	Unix                 unixSocketCfg `envconfig:"UNIX" json:"unix"`
+	TLS                  tlsCfg        `envconfig:"TLS" json:"tls"`
+	CORS                 bool          `envconfig:"CORS" default:"false" json:"cors"`
+	CORSAllowedOrigins   []string      `envconfig:"CORS_ALLOWED_ORIGINS" json:"cors_allowed_origins"`
+	CORSAllowCredentials bool          `envconfig:"CORS_ALLOW_CREDENTIALS" default:"false" json:"cors_allow_credentails"`
+	AccessLogInfoLevel   bool          `envconfig:"ACCESS_LOG_INFO" default:"false" json:"access_log_info"`
+	GRPCTranscode        bool          `envconfig:"GRPC_TRANSCODE" json:"grpc_transcode"` // serve JSON routes for GRPC services.
+	GRPCTranscodePrefix  string        `envconfig:"GRPC_TRANSCODE_PREFIX" default:"/api" json:"grpc_transcode_prefix"`
+
+	// Additional global CORS options, see also corsOptsCfg.
+	CORSAllowedOriginPatterns []string      `envconfig:"CORS_ALLOWED_ORIGIN_PATTERNS" json:"cors_allowed_origin_patterns"`
+	CORSAllowedMethods        []string      `envconfig:"CORS_ALLOWED_METHODS" json:"cors_allowed_methods"`
+	CORSAllowedHeaders        []string      `envconfig:"CORS_ALLOWED_HEADERS" json:"cors_allowed_headers"`
+	CORSExposedHeaders        []string      `envconfig:"CORS_EXPOSED_HEADERS" json:"cors_exposed_headers"`
+	CORSMaxAge                time.Duration `envconfig:"CORS_MAX_AGE" json:"cors_max_age"`
+	CORSAllowPrivateNetwork   bool          `envconfig:"CORS_ALLOW_PRIVATE_NETWORK" json:"cors_allow_private_network"`
+
+	// Per route prefix CORS settings, which apply even if CORS is false.
+	// Only settable from the config file.
+	CORSRoutes []corsRouteCfg `ignored:"true" json:"cors_routes"`
+
+	// Server timeouts, as in http.Server. Read and write timeouts are disabled
+	// by default as they also limit streaming responses and GRPC streams
+	// served on the HTTP server.
+	ReadTimeout       time.Duration `envconfig:"READ_TIMEOUT" json:"read_timeout"`
+	ReadHeaderTimeout time.Duration `envconfig:"READ_HEADER_TIMEOUT" default:"10s" json:"read_header_timeout"`
+	WriteTimeout      time.Duration `envconfig:"WRITE_TIMEOUT" json:"write_timeout"`
+	IdleTimeout       time.Duration `envconfig:"IDLE_TIMEOUT" default:"2m" json:"idle_timeout"`
+	MaxHeaderBytes    int           `envconfig:"MAX_HEADER_BYTES" default:"1048576" json:"max_header_bytes"`
+
+	// Request limits, not applied to GRPC requests served on the HTTP server.
+	// Handler timeout responses are buffered, so streaming responses are not
+	// possible when it is set.
+	MaxBodyBytes   int64         `envconfig:"MAX_BODY_BYTES" json:"max_body_bytes"`
+	HandlerTimeout time.Duration `envconfig:"HANDLER_TIMEOUT" json:"handler_timeout"` // responds with 503 once elapsed.
+
+	// Rejected requests get 429 with Retry-After.
+	RateLimit rateLimitCfg `envconfig:"RATE_LIMIT" json:"rate_limit"`
 }
 
 type grpcCfg struct {
-	Enabled        bool `envconfig:"ENABLED" default:"true" json:"enabled"`
-	Port           int  `envconfig:"PORT" default:"20001" json:"port"`
-	MaxSendMsgSize int  `envconfig:"MAX_SEND_MSG_SIZE" json:"max_send_msg_size"`
-	MaxRecvMsgSize int  `envconfig:"MAX_RECV_MSG_SIZE" json:"max_recv_msg_size"`
+	Enabled        bool          `envconfig:"ENABLED" default:"true" json:"enabled"`
+	Host           string        `envconfig:"HOST" json:"host"`
+	Port           int           `envconfig:"PORT" default:"20001" json:"port"`
+	Addrs          []string      `envconfig:"ADDRS" json:"addrs"` // if specified, overrides host and port. unix:// addresses are unix sockets.
+	Unix           unixSocketCfg `envconfig:"UNIX" json:"unix"`
+	TLS            tlsCfg        `envconfig:"TLS" json:"tls"`
+	ServeOnHTTP    bool          `envconfig:"SERVE_ON_HTTP" json:"serve_on_http"` // use the HTTP server listeners and TLS instead of separate ones.
+	MaxSendMsgSize int           `envconfig:"MAX_SEND_MSG_SIZE" json:"max_send_msg_size"`
+	MaxRecvMsgSize int           `envconfig:"MAX_RECV_MSG_SIZE" json:"max_recv_msg_size"`
+	Log            grpcLogCfg    `envconfig:"LOG" json:"log"`
+
+	// Zero values leave the GRPC defaults in place.
+	Keepalive             grpcKeepaliveCfg `envconfig:"KEEPALIVE" json:"keepalive"`
+	MaxConcurrentStreams  uint32           `envconfig:"MAX_CONCURRENT_STREAMS" json:"max_concurrent_streams"` // per connection.
+	ConnectionTimeout     time.Duration    `envconfig:"CONNECTION_TIMEOUT" json:"connection_timeout"`         // for connection establishment, including the TLS handshake.
+	InitialWindowSize     int32            `envconfig:"INITIAL_WINDOW_SIZE" json:"initial_window_size"`       // per stream, at least 64K.
+	InitialConnWindowSize int32            `envconfig:"INITIAL_CONN_WINDOW_SIZE" json:"initial_conn_window_size"`
+
+	// Rejected calls get ResourceExhausted with RetryInfo.
+	RateLimit rateLimitCfg `envconfig:"RATE_LIMIT" json:"rate_limit"`
+
+	// Register the reflection and channelz services. Off by default, as they
+	// expose the API schema and connection internals.
+	Reflection bool `envconfig:"REFLECTION" json:"reflection"`
+	Channelz   bool `envconfig:"CHANNELZ" json:"channelz"`
+
+	// Register svc.LogLevels, which allows changing log levels. Off by default
+	// as the GRPC server is usually not limited to operators.
+	LogLevelService bool `envconfig:"LOG_LEVEL_SERVICE" json:"log_level_service"`
+}
+
+type healthCfg struct {
+	Enabled            bool          `envconfig:"ENABLED" default:"true" json:"enabled"`
+	LivenessPath       string        `envconfig:"LIVENESS_PATH" default:"/healthz" json:"liveness_path"`
+	ReadinessPath      string        `envconfig:"READINESS_PATH" default:"/readyz" json:"readiness_path"`
+	HTTP               bool          `envconfig:"HTTP" json:"http"` // also serve the paths on the HTTP server, they are always served on the admin server.
+	GRPC               bool          `envconfig:"GRPC" json:"grpc"` // register grpc.health.v1.Health, unless already registered by a component.
+	GRPCUpdateInterval time.Duration `envconfig:"GRPC_UPDATE_INTERVAL" default:"5s" json:"grpc_update_interval"`
+}
+
+// Admin server for operational endpoints, see AdminRouter.
+type adminCfg struct {
+	Enabled bool          `envconfig:"ENABLED" default:"false" json:"enabled"`
+	Host    string        `envconfig:"HOST" default:"localhost" json:"host"`
+	Port    int           `envconfig:"PORT" default:"20002" json:"port"`
+	Addrs   []string      `envconfig:"ADDRS" json:"addrs"` // if specified, overrides host and port. unix:// addresses are unix sockets.
+	Unix    unixSocketCfg `envconfig:"UNIX" json:"unix"`
+
+	// Serve /config, which shows the svc and user configurations. Known
+	// secrets in the svc configuration are redacted, but user configuration
+	// fields must be excluded using a json:"-" tag.
+	Config bool `envconfig:"CONFIG" json:"config"`
+}
+
+type metricsCfg struct {
+	Enabled bool   `envconfig:"ENABLED" default:"true" json:"enabled"`
+	Path    string `envconfig:"URL_PATH" default:"/metrics" json:"path"` // not PATH, which envconfig would fall back to.
+	Server  string `envconfig:"SERVER" default:"admin" json:"server"`    // admin or http.
+}
+
+type tracingCfg struct {
+	Enabled      bool              `envconfig:"ENABLED" json:"enabled"`
+	Exporter     string            `envconfig:"EXPORTER" default:"stdout" json:"exporter"` // stdout, file, otlp-grpc or otlp-http.
+	File         string            `envconfig:"FILE" default:"traces.json" json:"file"`
+	OTLPEndpoint string            `envconfig:"OTLP_ENDPOINT" json:"otlp_endpoint"` // host:port, exporter default if empty.
+	OTLPInsecure bool              `envconfig:"OTLP_INSECURE" json:"otlp_insecure"`
+	OTLPHeaders  map[string]string `envconfig:"OTLP_HEADERS" json:"otlp_headers"`
+	SamplerRatio float64           `envconfig:"SAMPLER_RATIO" default:"1" json:"sampler_ratio"` // for root spans, otherwise follows parent.
+
+	// Added to service name and version attributes.
+	ResourceAttributes map[string]string `envconfig:"RESOURCE_ATTRIBUTES" json:"resource_attributes"`
 }
 
 type SvcCfg struct {
-	Log       Z.Config `envconfig:"LOG" json:"log"`
-	HTTP      httpCfg  `envconfig:"HTTP" json:"http"`
-	GRPC      grpcCfg  `envconfig:"GRPC" json:"grpc"`
-	PprofPort int      `envconfig:"PPROF_PORT" json:"pprof_port"`
+	Log        Z.Config   `envconfig:"LOG" json:"log"`
+	HTTP       httpCfg    `envconfig:"HTTP" json:"http"`
+	GRPC       grpcCfg    `envconfig:"GRPC" json:"grpc"`
+	Health     healthCfg  `envconfig:"HEALTH" json:"health"`
+	Admin      adminCfg   `envconfig:"ADMIN" json:"admin"`
+	Metrics    metricsCfg `envconfig:"METRICS" json:"metrics"`
+	Tracing    tracingCfg `envconfig:"TRACING" json:"tracing"`
+	PprofHost  string     `envconfig:"PPROF_HOST" default:"localhost" json:"pprof_host"`
+	PprofPort  int        `envconfig:"PPROF_PORT" json:"pprof_port"`
+	PprofAddrs []string   `envconfig:"PPROF_ADDRS" json:"pprof_addrs"` // if specified, overrides host and port.
+
+	// How long to wait for graceful shutdown once signaled to terminate.
+	ShutdownTimeout time.Duration `envconfig:"SHUTDOWN_TIMEOUT" default:"10s" json:"shutdown_timeout"`
+}
+
+// Returns a copy of c with known secrets replaced, for display.
+func (c *SvcCfg) redacted() *SvcCfg {
+	r := *c
+
+	// usually hold collector API keys.
+	if len(c.Tracing.OTLPHeaders) != 0 {
+		r.Tracing.OTLPHeaders = make(map[string]string, len(c.Tracing.OTLPHeaders))
+		for k := range c.Tracing.OTLPHeaders {
+			r.Tracing.OTLPHeaders[k] = "REDACTED"
+		}
+	}
+
+	return &r
 }
 
 func loadCfg(l L.L, name string, dst interface{}, path string) error {
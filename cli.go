@@ -25,33 +25,16 @@ func FlagsAndAction(optsargs ...OptFunc) ([]cli.Flag, func(c *cli.Context) error
 		ver, bg                           bool
 	)
 
-	cliFlags := []cli.Flag{
+	cliFlags := append([]cli.Flag{
 		&cli.StringFlag{
 			Name:        "config",
 			Aliases:     []string{"c"},
 			Destination: &flags.ConfigPath,
 			Usage:       "use config file",
 		},
-		&cli.StringSliceFlag{
-			Name:        "only",
-			Destination: &onlys,
-			Usage:       "enable only these modules",
-		},
-		&cli.StringSliceFlag{
-			Name:        "except",
-			Destination: &excepts,
-			Usage:       "disable only these modules",
-		},
-		&cli.StringSliceFlag{
-			Name:        "enable",
-			Destination: &enables,
-			Usage:       "modules to enable",
-		},
-		&cli.StringSliceFlag{
-			Name:        "disable",
-			Destination: &disables,
-			Usage:       "modules to disable",
-		},
+	}, selectionFlags(&enables, &disables, &onlys, &excepts)...)
+
+	cliFlags = append(cliFlags,
 		&cli.BoolFlag{
 			Name:        "background",
 			Aliases:     []string{"bg"},
@@ -79,7 +62,12 @@ func FlagsAndAction(optsargs ...OptFunc) ([]cli.Flag, func(c *cli.Context) error
 			Destination: &flags.PrintConfig,
 			Usage:       "print configuration",
 		},
-	}
+		&cli.BoolFlag{
+			Name:        "list-components",
+			Destination: &flags.ListComponents,
+			Usage:       "list components and whether they are enabled and exit",
+		},
+	)
 
 	if GetVersion() != nil {
 		cliFlags = append(cliFlags, &cli.BoolFlag{
@@ -120,12 +108,72 @@ func FlagsAndAction(optsargs ...OptFunc) ([]cli.Flag, func(c *cli.Context) error
 	return append(cliFlags, cliopts.flags...), cliAction, &cliopts
 }
 
+func selectionFlags(enables, disables, onlys, excepts *cli.StringSlice) []cli.Flag {
+	return []cli.Flag{
+		&cli.StringSliceFlag{
+			Name:        "only",
+			Destination: onlys,
+			Usage:       "enable only these modules (names, globs or tag:<tag>)",
+		},
+		&cli.StringSliceFlag{
+			Name:        "except",
+			Destination: excepts,
+			Usage:       "disable only these modules (names, globs or tag:<tag>)",
+		},
+		&cli.StringSliceFlag{
+			Name:        "enable",
+			Destination: enables,
+			Usage:       "modules to enable (names, globs or tag:<tag>)",
+		},
+		&cli.StringSliceFlag{
+			Name:        "disable",
+			Destination: disables,
+			Usage:       "modules to disable (names, globs or tag:<tag>)",
+		},
+	}
+}
+
+// Command that lists all registered components, and whether they are
+// enabled given the selection flags.
+func ComponentsCLICmd(opts ...OptFunc) *cli.Command {
+	var enables, disables, onlys, excepts cli.StringSlice
+
+	return &cli.Command{
+		Name:  "components",
+		Usage: "list components and whether they are enabled",
+		Flags: selectionFlags(&enables, &disables, &onlys, &excepts),
+		Action: func(c *cli.Context) error {
+			flags := Flags{
+				Enables:  enables.Value(),
+				Disables: disables.Value(),
+				Onlys:    onlys.Value(),
+				Excepts:  excepts.Value(),
+			}
+
+			cs, err := ResolveComponents(append(opts, WithFlags(&flags))...)
+			if err != nil {
+				return err
+			}
+
+			printComponents(os.Stdout, cs)
+
+			return nil
+		},
+	}
+}
+
 const usage = "autokitteh service"
 
 func CLICmd(name string, opts ...OptFunc) *cli.Command {
 	flags, action, _ := FlagsAndAction(opts...)
 
-	return &cli.Command{Name: name, Usage: usage, Flags: flags, Action: action}
+	return &cli.Command{
+		Name:        name,
+		Usage:       usage,
+		Flags:       flags,
+		Action:      action,
+		Subcommands: []*cli.Command{ComponentsCLICmd(opts...)},
+	}
 }
 
 func RunCLI(name string, opts ...OptFunc) {
@@ -135,7 +183,13 @@ func RunCLI(name string, opts ...OptFunc) {
 		name = DefaultServiceName
 	}
 
-	app := &cli.App{Name: name, Usage: usage, Flags: flags, Action: action}
+	app := &cli.App{
+		Name:     name,
+		Usage:    usage,
+		Flags:    flags,
+		Action:   action,
+		Commands: []*cli.Command{ComponentsCLICmd(opts...)},
+	}
 
 	for _, f := range cliopts.app {
 		f(app)
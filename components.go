@@ -0,0 +1,100 @@
+package svc
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+)
+
+// Resolved state of a single registered component.
+type ComponentStatus struct {
+	Name    string
+	Phases  []string // phases the component participates in: init, setup, start, ready.
+	Tags    []string
+	Enabled bool
+	Rule    string // describes what decided Enabled, for example "--only tag:workers".
+}
+
+// Resolve which components are enabled, given the specified options and
+// flags. Flags are taken from WithFlags, if not specified no flags are
+// assumed. Components are returned in order of registration.
+func ResolveComponents(optfs ...OptFunc) ([]ComponentStatus, error) {
+	var opts opts
+
+	for _, opt := range optfs {
+		opt(&opts)
+	}
+
+	flags := opts.flags
+	if flags == nil {
+		flags = &Flags{}
+	}
+
+	filter, err := newComponentsFilter(flags, &opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return resolveComponents(&opts, filter), nil
+}
+
+func resolveComponents(opts *opts, filter *componentsFilter) []ComponentStatus {
+	var (
+		cs  []ComponentStatus
+		idx = make(map[string]int)
+	)
+
+	phases := []struct {
+		name string
+		cbs  []callback
+	}{
+		{"init", opts.inits},
+		{"setup", opts.setups},
+		{"start", opts.starts},
+		{"ready", opts.readys},
+	}
+
+	for _, p := range phases {
+		for _, cb := range p.cbs {
+			i, ok := idx[cb.n]
+			if !ok {
+				enabled, rule := filter.resolve(cb.n)
+
+				i = len(cs)
+				idx[cb.n] = i
+
+				cs = append(cs, ComponentStatus{
+					Name:    cb.n,
+					Tags:    opts.tags[cb.n],
+					Enabled: enabled,
+					Rule:    rule,
+				})
+			}
+
+			cs[i].Phases = append(cs[i].Phases, p.name)
+		}
+	}
+
+	return cs
+}
+
+func printComponents(w io.Writer, cs []ComponentStatus) {
+	tabs := tabwriter.NewWriter(w, 1, 0, 4, ' ', 0)
+
+	fmt.Fprintln(tabs, "NAME	PHASES	TAGS	ENABLED	RULE")
+
+	for _, c := range cs {
+		fmt.Fprintf(
+			tabs,
+			"%s	%s	%s	%v	%s\n",
+			c.Name,
+			strings.Join(c.Phases, ","),
+			strings.Join(c.Tags, ","),
+			c.Enabled,
+			c.Rule,
+		)
+	}
+
+	tabs.Flush()
+}
@@ -0,0 +1,165 @@
+package svc
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/rs/cors"
+)
+
+type corsOptsCfg struct {
+	AllowedOrigins        []string      `json:"allowed_origins"`         // may contain a single "*" wildcard each.
+	AllowedOriginPatterns []string      `json:"allowed_origin_patterns"` // regular expressions matched against the whole origin.
+	AllowedMethods        []string      `json:"allowed_methods"`         // rs/cors defaults if empty.
+	AllowedHeaders        []string      `json:"allowed_headers"`         // rs/cors defaults if empty.
+	ExposedHeaders        []string      `json:"exposed_headers"`
+	MaxAge                time.Duration `json:"max_age"`
+	AllowCredentials      bool          `json:"allow_credentials"`
+	AllowPrivateNetwork   bool          `json:"allow_private_network"`
+}
+
+// CORS settings for paths under Prefix, see hasPathPrefix. Overrides the
+// global settings, if any, for these paths.
+type corsRouteCfg struct {
+	Prefix string `json:"prefix"`
+	corsOptsCfg
+}
+
+func (c httpCfg) corsOpts() corsOptsCfg {
+	return corsOptsCfg{
+		AllowedOrigins:        c.CORSAllowedOrigins,
+		AllowedOriginPatterns: c.CORSAllowedOriginPatterns,
+		AllowedMethods:        c.CORSAllowedMethods,
+		AllowedHeaders:        c.CORSAllowedHeaders,
+		ExposedHeaders:        c.CORSExposedHeaders,
+		MaxAge:                c.CORSMaxAge,
+		AllowCredentials:      c.CORSAllowCredentials,
+		AllowPrivateNetwork:   c.CORSAllowPrivateNetwork,
+	}
+}
+
+// Returns true if p is prefix or is under it, so "/api" matches "/api" and
+// "/api/x", but not "/apix".
+func hasPathPrefix(p, prefix string) bool {
+	if !strings.HasPrefix(p, prefix) {
+		return false
+	}
+
+	return len(p) == len(prefix) || strings.HasSuffix(prefix, "/") || p[len(prefix)] == '/'
+}
+
+// Convert an origin with an optional wildcard, as accepted by rs/cors, to
+// a regular expression.
+func originRegexp(o string) string {
+	if o == "*" {
+		return ".*"
+	}
+
+	return strings.ReplaceAll(regexp.QuoteMeta(o), `\*`, ".*")
+}
+
+func (c corsOptsCfg) cors() (*cors.Cors, error) {
+	opts := cors.Options{
+		AllowedOrigins:      c.AllowedOrigins,
+		AllowedMethods:      c.AllowedMethods,
+		AllowedHeaders:      c.AllowedHeaders,
+		ExposedHeaders:      c.ExposedHeaders,
+		MaxAge:              int(c.MaxAge.Seconds()),
+		AllowCredentials:    c.AllowCredentials,
+		AllowPrivateNetwork: c.AllowPrivateNetwork,
+	}
+
+	if len(c.AllowedOriginPatterns) != 0 {
+		// rs/cors ignores AllowedOrigins if AllowOriginFunc is set, so both
+		// are matched here.
+		var res []*regexp.Regexp
+
+		for _, o := range c.AllowedOrigins {
+			res = append(res, regexp.MustCompile("(?i)^"+originRegexp(o)+"$"))
+		}
+
+		for _, p := range c.AllowedOriginPatterns {
+			re, err := regexp.Compile("^(?:" + p + ")$")
+			if err != nil {
+				return nil, fmt.Errorf("origin pattern %q: %w", p, err)
+			}
+
+			res = append(res, re)
+		}
+
+		opts.AllowOriginFunc = func(origin string) bool {
+			for _, re := range res {
+				if re.MatchString(origin) {
+					return true
+				}
+			}
+
+			return false
+		}
+	}
+
+	return cors.New(opts), nil
+}
+
+// Returns a middleware that applies the global CORS settings if enabled,
+// and the settings of the longest matching route prefix otherwise. Returns
+// nil if CORS is not configured at all.
+func newCORS(cfg httpCfg) (func(http.Handler) http.Handler, error) {
+	var global *cors.Cors
+
+	if cfg.CORS {
+		var err error
+		if global, err = cfg.corsOpts().cors(); err != nil {
+			return nil, err
+		}
+	}
+
+	if global == nil && len(cfg.CORSRoutes) == 0 {
+		return nil, nil
+	}
+
+	type route struct {
+		prefix string
+		c      *cors.Cors
+	}
+
+	routes := make([]route, len(cfg.CORSRoutes))
+
+	for i, rc := range cfg.CORSRoutes {
+		c, err := rc.cors()
+		if err != nil {
+			return nil, fmt.Errorf("route %q: %w", rc.Prefix, err)
+		}
+
+		routes[i] = route{prefix: rc.Prefix, c: c}
+	}
+
+	sort.SliceStable(routes, func(i, j int) bool { return len(routes[i].prefix) > len(routes[j].prefix) })
+
+	return func(next http.Handler) http.Handler {
+		hs := make([]http.Handler, len(routes))
+		for i, r := range routes {
+			hs[i] = r.c.Handler(next)
+		}
+
+		def := next
+		if global != nil {
+			def = global.Handler(next)
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			for i, route := range routes {
+				if hasPathPrefix(r.URL.Path, route.prefix) {
+					hs[i].ServeHTTP(w, r)
+					return
+				}
+			}
+
+			def.ServeHTTP(w, r)
+		})
+	}, nil
+}
@@ -0,0 +1,134 @@
+//go:build unit
+
+package svc
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+
+	"github.com/autokitteh/L"
+	"github.com/autokitteh/L/Z"
+)
+
+func corsRequest(t *testing.T, h http.Handler, path, origin string) http.Header {
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	req.Header.Set("Origin", origin)
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	return rec.Header()
+}
+
+func TestCORSDisabled(t *testing.T) {
+	withCORS, err := newCORS(httpCfg{})
+	require.NoError(t, err)
+	assert.Nil(t, withCORS)
+}
+
+func TestCORSOriginPatterns(t *testing.T) {
+	withCORS, err := newCORS(httpCfg{
+		CORS:                      true,
+		CORSAllowedOrigins:        []string{"https://*.cats.com"},
+		CORSAllowedOriginPatterns: []string{`https://dog[0-9]+\.com`},
+		CORSExposedHeaders:        []string{"X-Meow"},
+	})
+	require.NoError(t, err)
+
+	h := withCORS(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+
+	for origin, ok := range map[string]bool{
+		"https://garfield.cats.com": true,
+		"https://Garfield.Cats.com": true,
+		"https://dog1.com":          true,
+		"https://dog.com":           false,
+		"https://dog1.com.evil":     false,
+	} {
+		hdr := corsRequest(t, h, "/", origin)
+
+		if ok {
+			assert.Equal(t, origin, hdr.Get("Access-Control-Allow-Origin"), origin)
+			assert.Equal(t, "X-Meow", hdr.Get("Access-Control-Expose-Headers"), origin)
+		} else {
+			assert.Empty(t, hdr.Get("Access-Control-Allow-Origin"), origin)
+		}
+	}
+
+	_, err = newCORS(httpCfg{CORS: true, CORSAllowedOriginPatterns: []string{"("}})
+	assert.Error(t, err)
+}
+
+func TestCORSRoutes(t *testing.T) {
+	withCORS, err := newCORS(httpCfg{
+		CORS:               true,
+		CORSAllowedOrigins: []string{"https://cats.com"},
+		CORSRoutes: []corsRouteCfg{
+			{Prefix: "/api", corsOptsCfg: corsOptsCfg{AllowedOrigins: []string{"https://dogs.com"}}},
+			{Prefix: "/api/public", corsOptsCfg: corsOptsCfg{AllowedOrigins: []string{"*"}}},
+		},
+	})
+	require.NoError(t, err)
+
+	h := withCORS(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+
+	assert.Equal(t, "https://cats.com", corsRequest(t, h, "/", "https://cats.com").Get("Access-Control-Allow-Origin"))
+	assert.Empty(t, corsRequest(t, h, "/api/x", "https://cats.com").Get("Access-Control-Allow-Origin"))
+	assert.Equal(t, "https://dogs.com", corsRequest(t, h, "/api/x", "https://dogs.com").Get("Access-Control-Allow-Origin"))
+	assert.Equal(t, "*", corsRequest(t, h, "/api/public/x", "https://mice.com").Get("Access-Control-Allow-Origin"))
+	assert.Equal(t, "https://dogs.com", corsRequest(t, h, "/api", "https://dogs.com").Get("Access-Control-Allow-Origin"))
+	assert.Equal(t, "https://cats.com", corsRequest(t, h, "/apix", "https://cats.com").Get("Access-Control-Allow-Origin"))
+	assert.Equal(t, "https://dogs.com", corsRequest(t, h, "/api/publicity", "https://dogs.com").Get("Access-Control-Allow-Origin"))
+}
+
+func TestHasPathPrefix(t *testing.T) {
+	for _, test := range []struct {
+		p, prefix string
+		ok        bool
+	}{
+		{"/api", "/api", true},
+		{"/api/x", "/api", true},
+		{"/apix", "/api", false},
+		{"/api/x", "/api/", true},
+		{"/api", "/api/", false},
+		{"/x", "/", true},
+		{"/x", "", true},
+	} {
+		assert.Equal(t, test.ok, hasPathPrefix(test.p, test.prefix), "%q %q", test.p, test.prefix)
+	}
+}
+
+// CORS used to replace the access log handler.
+func TestCORSAccessLog(t *testing.T) {
+	core, logs := observer.New(zapcore.DebugLevel)
+	l := &Z.ZL{Z: zap.New(core).Sugar()}
+
+	withCORS, err := newCORS(httpCfg{CORS: true, CORSAllowedOrigins: []string{"*"}})
+	require.NoError(t, err)
+
+	r := mux.NewRouter()
+	r.HandleFunc("/", func(http.ResponseWriter, *http.Request) {})
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	sd := newShutdown(L.Nop, 0)
+	defer sd.run()
+
+	addr := startHTTP(l, r, nil, nil, httpCfg{}, withCORS, nil, nil, []net.Listener{lis}, sd, make(chan error, 1))
+
+	resp, err := http.Get("http://" + addr.Addr.String() + "/")
+	require.NoError(t, err)
+	resp.Body.Close()
+
+	require.Equal(t, 1, logs.FilterMessage("http").Len())
+	assert.Equal(t, resp.Header.Get(RequestIDHeader), logs.FilterMessage("http").All()[0].ContextMap()[requestIDLogField])
+}
@@ -0,0 +1,191 @@
+package svc
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+)
+
+// Prefix for selectors that select components by tag rather than by name.
+const tagSelectorPrefix = "tag:"
+
+// A selector selects components either by name or by tag, as given to
+// --enable, --disable, --only and --except. Both names and tags may contain
+// glob patterns, as accepted by path.Match.
+//
+// Examples:
+//
+//	api        - component named "api".
+//	api-*      - all components with names that start with "api-".
+//	tag:worker - all components tagged with "worker".
+//	tag:work*  - all components with a tag that starts with "work".
+type selector string
+
+func (s selector) validate() error {
+	p := strings.TrimPrefix(string(s), tagSelectorPrefix)
+
+	if p == "" {
+		return fmt.Errorf("empty selector %q", s)
+	}
+
+	if _, err := path.Match(p, ""); err != nil {
+		return fmt.Errorf("invalid selector %q: %w", s, err)
+	}
+
+	return nil
+}
+
+func (s selector) match(name string, tags []string) bool {
+	if p := string(s); strings.HasPrefix(p, tagSelectorPrefix) {
+		p = strings.TrimPrefix(p, tagSelectorPrefix)
+
+		for _, t := range tags {
+			if ok, _ := path.Match(p, t); ok {
+				return true
+			}
+		}
+
+		return false
+	}
+
+	ok, _ := path.Match(string(s), name)
+	return ok
+}
+
+type selectors []string
+
+func (ss selectors) validate() error {
+	for _, s := range ss {
+		if err := selector(s).validate(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Returns the first selector that matches.
+func (ss selectors) find(name string, tags []string) (string, bool) {
+	for _, s := range ss {
+		if selector(s).match(name, tags) {
+			return s, true
+		}
+	}
+
+	return "", false
+}
+
+type componentsFilter struct {
+	enables, disables, defaultDisables, onlys, excepts selectors
+	tags                                               map[string][]string
+}
+
+func newComponentsFilter(flags *Flags, opts *opts) (*componentsFilter, error) {
+	if len(flags.Onlys) != 0 && len(flags.Excepts) != 0 {
+		return nil, errors.New("--only and --except are mutually exclusive")
+	}
+
+	f := componentsFilter{
+		enables:         flags.Enables,
+		disables:        flags.Disables,
+		onlys:           flags.Onlys,
+		excepts:         flags.Excepts,
+		defaultDisables: opts.defaultDisables,
+		tags:            opts.tags,
+	}
+
+	for _, ss := range []selectors{f.enables, f.disables, f.onlys, f.excepts} {
+		if err := ss.validate(); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, e := range f.enables {
+		for _, d := range f.disables {
+			if e == d {
+				return nil, fmt.Errorf("%q is both enabled and disabled", e)
+			}
+		}
+	}
+
+	// overlapping patterns can only be detected against actual components.
+	for _, cbs := range [][]callback{opts.inits, opts.setups, opts.starts, opts.readys} {
+		for _, cb := range cbs {
+			tags := f.tags[cb.n]
+
+			if e, ok := f.enables.find(cb.n, tags); ok {
+				if d, ok := f.disables.find(cb.n, tags); ok {
+					return nil, fmt.Errorf("%q is both enabled by %q and disabled by %q", cb.n, e, d)
+				}
+			}
+		}
+	}
+
+	return &f, nil
+}
+
+// Determine if a component is enabled, and describe the rule that decided
+// it. Rules are evaluated in the following order, first match wins:
+// 1. --enable.
+// 2. --disable.
+// 3. Disabled by default (WithDefaultDisable or Component.Disabled).
+// 4. --only: enabled if matched, disabled otherwise.
+// 5. --except: disabled if matched, enabled otherwise.
+// 6. Enabled.
+func (f *componentsFilter) resolve(n string) (bool, string) {
+	tags := f.tags[n]
+
+	if s, ok := f.enables.find(n, tags); ok {
+		return true, "--enable " + s
+	}
+
+	if s, ok := f.disables.find(n, tags); ok {
+		return false, "--disable " + s
+	}
+
+	// default disables are always specified by name.
+	for _, d := range f.defaultDisables {
+		if n == d {
+			return false, "disabled by default"
+		}
+	}
+
+	if len(f.onlys) != 0 {
+		if s, ok := f.onlys.find(n, tags); ok {
+			return true, "--only " + s
+		}
+
+		return false, "not in --only"
+	}
+
+	if len(f.excepts) != 0 {
+		if s, ok := f.excepts.find(n, tags); ok {
+			return false, "--except " + s
+		}
+
+		return true, "not in --except"
+	}
+
+	return true, "enabled by default"
+}
+
+func (f *componentsFilter) enabled(n string) bool {
+	enabled, _ := f.resolve(n)
+	return enabled
+}
+
+func (f *componentsFilter) filter(cbs []callback) (enabled, disabled []callback) {
+	enabled = make([]callback, 0, len(cbs))
+	disabled = make([]callback, 0, len(cbs))
+
+	for _, cb := range cbs {
+		if f.enabled(cb.n) {
+			enabled = append(enabled, cb)
+		} else {
+			disabled = append(disabled, cb)
+		}
+	}
+
+	return
+}
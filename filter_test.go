@@ -0,0 +1,88 @@
+//go:build unit
+
+package svc
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSelectorMatch(t *testing.T) {
+	tests := []struct {
+		s    selector
+		n    string
+		tags []string
+		ok   bool
+	}{
+		{s: "api", n: "api", ok: true},
+		{s: "api", n: "api-1"},
+		{s: "api-*", n: "api-1", ok: true},
+		{s: "api-*", n: "worker"},
+		{s: "tag:workers", n: "w1", tags: []string{"workers"}, ok: true},
+		{s: "tag:workers", n: "workers"},
+		{s: "tag:work*", n: "w1", tags: []string{"api", "workers"}, ok: true},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.ok, test.s.match(test.n, test.tags), "%q %q %v", test.s, test.n, test.tags)
+	}
+}
+
+func TestSelectorValidate(t *testing.T) {
+	assert.NoError(t, selector("api-*").validate())
+	assert.NoError(t, selector("tag:w*").validate())
+	assert.Error(t, selector("api-[").validate())
+	assert.Error(t, selector("tag:").validate())
+}
+
+func TestComponentsFilter(t *testing.T) {
+	f := componentsFilter{
+		onlys:           []string{"api-*", "tag:workers"},
+		disables:        []string{"api-2"},
+		defaultDisables: []string{"w2"},
+		tags:            map[string][]string{"w1": {"workers"}, "w2": {"workers"}},
+	}
+
+	assert.True(t, f.enabled("api-1"))
+	assert.False(t, f.enabled("api-2"))
+	assert.True(t, f.enabled("w1"))
+	assert.False(t, f.enabled("w2"))
+	assert.False(t, f.enabled("scheduler"))
+}
+
+func TestComponentsFilterValidation(t *testing.T) {
+	_, err := newComponentsFilter(&Flags{Onlys: []string{"a"}, Excepts: []string{"b"}}, &opts{})
+	assert.EqualError(t, err, "--only and --except are mutually exclusive")
+
+	_, err = newComponentsFilter(&Flags{Enables: []string{"a"}, Disables: []string{"a"}}, &opts{})
+	assert.Error(t, err)
+
+	o := opts{starts: []callback{{n: "dbx"}, {n: "w1"}}, tags: map[string][]string{"w1": {"workers"}}}
+
+	_, err = newComponentsFilter(&Flags{Enables: []string{"db*"}, Disables: []string{"dbx"}}, &o)
+	assert.EqualError(t, err, `"dbx" is both enabled by "db*" and disabled by "dbx"`)
+
+	_, err = newComponentsFilter(&Flags{Enables: []string{"tag:workers"}, Disables: []string{"w*"}}, &o)
+	assert.Error(t, err)
+
+	_, err = newComponentsFilter(&Flags{Enables: []string{"db*"}, Disables: []string{"w*"}}, &o)
+	assert.NoError(t, err)
+}
+
+func TestResolveComponents(t *testing.T) {
+	cs, err := ResolveComponents(
+		WithComponent(Component{Name: "api", Init: func() {}, Start: func() {}}),
+		WithComponent(Component{Name: "w1", Start: func() {}, Tags: []string{"workers"}}),
+		WithComponent(Component{Name: "sched", Ready: func() {}, Disabled: true}),
+		WithFlags(&Flags{Excepts: []string{"tag:workers"}}),
+	)
+
+	if assert.NoError(t, err) {
+		assert.Equal(t, []ComponentStatus{
+			{Name: "api", Phases: []string{"init", "start"}, Enabled: true, Rule: "not in --except"},
+			{Name: "w1", Phases: []string{"start"}, Tags: []string{"workers"}, Rule: "--except tag:workers"},
+			{Name: "sched", Phases: []string{"ready"}, Rule: "disabled by default"},
+		}, cs)
+	}
+}
@@ -1,12 +1,126 @@
 package svc
 
 import (
+	"context"
+	"sort"
+	"sync"
+
+	grpc_middleware "github.com/grpc-ecosystem/go-grpc-middleware"
 	"google.golang.org/grpc"
 )
 
+type namedInterceptor struct {
+	name     string
+	priority int
+	unary    grpc.UnaryServerInterceptor
+	stream   grpc.StreamServerInterceptor
+}
+
 // Specified GRPC Server Options for the server.
-// Can only be used by the Init phase.
-// Can be used, for example, to add interceptors by components.
-type GRPCOptions struct{ opts []grpc.ServerOption }
+//
+// Options given to Add can only be used by the Init phase. Interceptors
+// given as options, using grpc.UnaryInterceptor for example, precede the
+// chain below.
+//
+// Interceptors added using AddUnaryInterceptor and AddStreamInterceptor
+// can be added by both the Init and Start phases, even after the server
+// was created. The complete chain, from the outermost, is:
+//  1. Panic recovery, for panics in the interceptors below.
+//  2. Tracing, if enabled.
+//  3. Metrics, if enabled.
+//  4. Logging.
+//  5. Request ID (see RequestIDFromContext).
+//  6. Rate limiting, if enabled.
+//  7. Payload logging, if enabled.
+//  8. Panic recovery, so the interceptors above observe recovered calls
+//     as failed with codes.Internal.
+//  9. Component interceptors, by ascending priority. Interceptors with the
+//     same priority are in the order added.
+type GRPCOptions struct {
+	opts []grpc.ServerOption
+
+	mu          sync.RWMutex
+	unarys      []namedInterceptor
+	streams     []namedInterceptor
+	unaryChain  grpc.UnaryServerInterceptor  // nil if no unary interceptors.
+	streamChain grpc.StreamServerInterceptor // nil if no stream interceptors.
+}
 
 func (g *GRPCOptions) Add(opts ...grpc.ServerOption) { g.opts = append(g.opts, opts...) }
+
+func addInterceptor(is []namedInterceptor, i namedInterceptor) []namedInterceptor {
+	is = append(is, i)
+
+	sort.SliceStable(is, func(i, j int) bool { return is[i].priority < is[j].priority })
+
+	return is
+}
+
+func (g *GRPCOptions) AddUnaryInterceptor(name string, priority int, i grpc.UnaryServerInterceptor) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.unarys = addInterceptor(g.unarys, namedInterceptor{name: name, priority: priority, unary: i})
+
+	is := make([]grpc.UnaryServerInterceptor, len(g.unarys))
+	for j, u := range g.unarys {
+		is[j] = u.unary
+	}
+
+	g.unaryChain = grpc_middleware.ChainUnaryServer(is...)
+}
+
+func (g *GRPCOptions) AddStreamInterceptor(name string, priority int, i grpc.StreamServerInterceptor) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.streams = addInterceptor(g.streams, namedInterceptor{name: name, priority: priority, stream: i})
+
+	is := make([]grpc.StreamServerInterceptor, len(g.streams))
+	for j, s := range g.streams {
+		is[j] = s.stream
+	}
+
+	g.streamChain = grpc_middleware.ChainStreamServer(is...)
+}
+
+// Names of the component interceptors, in chain order.
+func (g *GRPCOptions) interceptorsNames() (unarys, streams []string) {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	for _, i := range g.unarys {
+		unarys = append(unarys, i.name)
+	}
+
+	for _, i := range g.streams {
+		streams = append(streams, i.name)
+	}
+
+	return
+}
+
+// Dispatches to the component interceptors added at the time of the call.
+func (g *GRPCOptions) unaryInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	g.mu.RLock()
+	chain := g.unaryChain
+	g.mu.RUnlock()
+
+	if chain == nil {
+		return handler(ctx, req)
+	}
+
+	return chain(ctx, req, info, handler)
+}
+
+func (g *GRPCOptions) streamInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	g.mu.RLock()
+	chain := g.streamChain
+	g.mu.RUnlock()
+
+	if chain == nil {
+		return handler(srv, ss)
+	}
+
+	return chain(srv, ss, info, handler)
+}
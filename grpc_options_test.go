@@ -0,0 +1,65 @@
+//go:build unit
+
+package svc
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+func TestGRPCOptionsInterceptors(t *testing.T) {
+	var (
+		opts  GRPCOptions
+		trail []string
+	)
+
+	add := func(name string, priority int) {
+		opts.AddUnaryInterceptor(name, priority, func(ctx context.Context, req interface{}, _ *grpc.UnaryServerInfo, h grpc.UnaryHandler) (interface{}, error) {
+			trail = append(trail, name)
+			return h(ctx, req)
+		})
+	}
+
+	add("b", 1)
+
+	// conflicted with the svc interceptors when using grpc.UnaryInterceptor.
+	opts.Add(grpc.UnaryInterceptor(func(ctx context.Context, req interface{}, _ *grpc.UnaryServerInfo, h grpc.UnaryHandler) (interface{}, error) {
+		trail = append(trail, "option")
+		return h(ctx, req)
+	}))
+
+	opts.Add(grpc.ChainUnaryInterceptor(opts.unaryInterceptor))
+
+	srv := grpc.NewServer(opts.opts...)
+	healthpb.RegisterHealthServer(srv, health.NewServer())
+
+	// added after the server was created.
+	add("a", 0)
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	go func() { _ = srv.Serve(lis) }()
+	defer srv.Stop()
+
+	conn, err := grpc.Dial(lis.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	defer conn.Close()
+
+	_, err = healthpb.NewHealthClient(conn).Check(context.Background(), &healthpb.HealthCheckRequest{})
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"option", "a", "b"}, trail)
+
+	unarys, streams := opts.interceptorsNames()
+	assert.Equal(t, []string{"a", "b"}, unarys)
+	assert.Empty(t, streams)
+}
@@ -0,0 +1,39 @@
+package svc
+
+import (
+	"google.golang.org/grpc"
+	channelz "google.golang.org/grpc/channelz/service"
+	"google.golang.org/grpc/reflection"
+
+	"github.com/autokitteh/L"
+)
+
+const (
+	reflectionServiceName = "grpc.reflection.v1alpha.ServerReflection"
+	channelzServiceName   = "grpc.channelz.v1.Channelz"
+)
+
+// Register the reflection and channelz services, if enabled. Services that
+// were already registered by components are left as is. Called after the
+// start phase, so all services are registered by then.
+func registerGRPCDebugServices(l L.L, srv *grpc.Server, cfg grpcCfg) {
+	infos := srv.GetServiceInfo()
+
+	register := func(name string, enabled bool, f func()) {
+		if !enabled {
+			return
+		}
+
+		if _, ok := infos[name]; ok {
+			l.Debug("service already registered", "service", name)
+			return
+		}
+
+		f()
+
+		l.Debug("registered", "service", name)
+	}
+
+	register(reflectionServiceName, cfg.Reflection, func() { reflection.Register(srv) })
+	register(channelzServiceName, cfg.Channelz, func() { channelz.RegisterChannelzServiceToServer(srv) })
+}
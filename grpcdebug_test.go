@@ -0,0 +1,38 @@
+//go:build unit
+
+package svc
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/reflection"
+
+	"github.com/autokitteh/L"
+)
+
+func TestGRPCDebugServicesDefaults(t *testing.T) {
+	srv := grpc.NewServer()
+
+	registerGRPCDebugServices(L.Nop, srv, grpcCfg{})
+
+	infos := srv.GetServiceInfo()
+
+	assert.NotContains(t, infos, reflectionServiceName)
+	assert.NotContains(t, infos, channelzServiceName)
+}
+
+func TestGRPCDebugServicesExplicit(t *testing.T) {
+	srv := grpc.NewServer()
+
+	// already registered by a component.
+	reflection.Register(srv)
+
+	registerGRPCDebugServices(L.Nop, srv, grpcCfg{Reflection: true, Channelz: true})
+
+	infos := srv.GetServiceInfo()
+
+	assert.Contains(t, infos, reflectionServiceName)
+	assert.Contains(t, infos, channelzServiceName)
+}
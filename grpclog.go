@@ -0,0 +1,214 @@
+package svc
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strings"
+	"time"
+
+	grpc_zap "github.com/grpc-ecosystem/go-grpc-middleware/logging/zap"
+	"github.com/grpc-ecosystem/go-grpc-middleware/logging/zap/ctxzap"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// Method patterns are matched using path.Match against the full method name
+// without the leading slash, for example "grpc.health.v1.Health/*".
+type grpcLogCfg struct {
+	// Overrides grpc_zap.DefaultCodeToLevel, for example "NotFound:debug,Canceled:info".
+	CodeLevels map[string]string `envconfig:"CODE_LEVELS" json:"code_levels"`
+	Include    []string          `envconfig:"INCLUDE" json:"include"` // if specified, only these methods are logged.
+	Exclude    []string          `envconfig:"EXCLUDE" default:"grpc.health.v1.Health/*" json:"exclude"`
+
+	Payloads       bool `envconfig:"PAYLOADS" json:"payloads"`
+	PayloadMaxSize int  `envconfig:"PAYLOAD_MAX_SIZE" default:"1024" json:"payload_max_size"` // in bytes, longer payloads are truncated.
+
+	// Calls that take at least this long are logged at least at SlowLevel.
+	SlowThreshold time.Duration `envconfig:"SLOW_THRESHOLD" json:"slow_threshold"`
+	SlowLevel     string        `envconfig:"SLOW_LEVEL" default:"warn" json:"slow_level"`
+}
+
+type grpcLogger struct {
+	cfg        grpcLogCfg
+	codeLevels map[codes.Code]zapcore.Level
+	slowLevel  zapcore.Level
+}
+
+func newGRPCLogger(cfg grpcLogCfg) (*grpcLogger, error) {
+	g := grpcLogger{cfg: cfg, codeLevels: make(map[codes.Code]zapcore.Level, len(cfg.CodeLevels))}
+
+	names := make(map[string]codes.Code, 17)
+	for c := codes.OK; c <= codes.Unauthenticated; c++ {
+		names[strings.ToLower(c.String())] = c
+	}
+
+	for k, v := range cfg.CodeLevels {
+		c, ok := names[strings.ToLower(k)]
+		if !ok {
+			return nil, fmt.Errorf("unknown code %q", k)
+		}
+
+		var level zapcore.Level
+		if err := level.UnmarshalText([]byte(v)); err != nil {
+			return nil, fmt.Errorf("code %q level: %w", k, err)
+		}
+
+		g.codeLevels[c] = level
+	}
+
+	if err := g.slowLevel.UnmarshalText([]byte(cfg.SlowLevel)); err != nil {
+		return nil, fmt.Errorf("slow level: %w", err)
+	}
+
+	for _, p := range append(cfg.Include, cfg.Exclude...) {
+		if _, err := path.Match(p, ""); err != nil {
+			return nil, fmt.Errorf("method pattern %q: %w", p, err)
+		}
+	}
+
+	return &g, nil
+}
+
+func matchMethod(patterns []string, fullMethod string) bool {
+	fullMethod = strings.TrimPrefix(fullMethod, "/")
+
+	for _, p := range patterns {
+		if ok, _ := path.Match(p, fullMethod); ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (g *grpcLogger) shouldLog(fullMethod string) bool {
+	if len(g.cfg.Include) != 0 && !matchMethod(g.cfg.Include, fullMethod) {
+		return false
+	}
+
+	return !matchMethod(g.cfg.Exclude, fullMethod)
+}
+
+func (g *grpcLogger) level(code codes.Code) zapcore.Level {
+	if level, ok := g.codeLevels[code]; ok {
+		return level
+	}
+
+	return grpc_zap.DefaultCodeToLevel(code)
+}
+
+// Logged the same as grpc_zap.DurationToTimeMillisField, but keeps the
+// duration so the message producer can use it.
+type grpcDurationField time.Duration
+
+func (d grpcDurationField) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	enc.AddFloat32("grpc.time_ms", float32(time.Duration(d).Nanoseconds()/1000)/1000)
+	return nil
+}
+
+func durationField(d time.Duration) zapcore.Field { return zap.Inline(grpcDurationField(d)) }
+
+// Same as grpc_zap.DefaultMessageProducer, but escalates slow calls.
+func (g *grpcLogger) message(ctx context.Context, msg string, level zapcore.Level, code codes.Code, err error, duration zapcore.Field) {
+	fields := []zapcore.Field{zap.Error(err), zap.String("grpc.code", code.String()), duration}
+
+	// duration is made by durationField, see options.
+	d, _ := duration.Interface.(grpcDurationField)
+
+	if t := g.cfg.SlowThreshold; t > 0 && time.Duration(d) >= t {
+		if level < g.slowLevel {
+			level = g.slowLevel
+		}
+
+		fields = append(fields, zap.Bool("grpc.slow", true))
+	}
+
+	ctxzap.Extract(ctx).Check(level, msg).Write(fields...)
+}
+
+func (g *grpcLogger) options() []grpc_zap.Option {
+	return []grpc_zap.Option{
+		grpc_zap.WithDecider(func(fullMethod string, _ error) bool { return g.shouldLog(fullMethod) }),
+		grpc_zap.WithLevels(g.level),
+		grpc_zap.WithDurationField(durationField),
+		grpc_zap.WithMessageProducer(g.message),
+	}
+}
+
+func (g *grpcLogger) payloadField(key string, m interface{}) zapcore.Field {
+	var s string
+
+	if pm, ok := m.(proto.Message); ok {
+		bs, err := protojson.Marshal(pm)
+		if err != nil {
+			return zap.NamedError(key+"_error", err)
+		}
+
+		s = string(bs)
+	} else {
+		s = fmt.Sprintf("%v", m)
+	}
+
+	if max := g.cfg.PayloadMaxSize; max > 0 && len(s) > max {
+		return zap.String(key, s[:max]+fmt.Sprintf("... (%d bytes truncated)", len(s)-max))
+	}
+
+	return zap.String(key, s)
+}
+
+func (g *grpcLogger) logPayload(ctx context.Context, key string, m interface{}) {
+	ctxzap.Extract(ctx).Info("payload", g.payloadField(key, m))
+}
+
+// Must be chained after grpc_zap's interceptor, which puts the logger in
+// the context.
+func (g *grpcLogger) payloadUnaryInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	if !g.shouldLog(info.FullMethod) {
+		return handler(ctx, req)
+	}
+
+	g.logPayload(ctx, "grpc.request.content", req)
+
+	resp, err := handler(ctx, req)
+	if err == nil {
+		g.logPayload(ctx, "grpc.response.content", resp)
+	}
+
+	return resp, err
+}
+
+func (g *grpcLogger) payloadStreamInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if !g.shouldLog(info.FullMethod) {
+		return handler(srv, ss)
+	}
+
+	return handler(srv, &payloadLoggingStream{ServerStream: ss, g: g})
+}
+
+type payloadLoggingStream struct {
+	grpc.ServerStream
+	g *grpcLogger
+}
+
+func (s *payloadLoggingStream) SendMsg(m interface{}) error {
+	err := s.ServerStream.SendMsg(m)
+	if err == nil {
+		s.g.logPayload(s.Context(), "grpc.response.content", m)
+	}
+
+	return err
+}
+
+func (s *payloadLoggingStream) RecvMsg(m interface{}) error {
+	err := s.ServerStream.RecvMsg(m)
+	if err == nil {
+		s.g.logPayload(s.Context(), "grpc.request.content", m)
+	}
+
+	return err
+}
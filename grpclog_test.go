@@ -0,0 +1,117 @@
+//go:build unit
+
+package svc
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	grpc_zap "github.com/grpc-ecosystem/go-grpc-middleware/logging/zap"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+func TestGRPCLogCfg(t *testing.T) {
+	_, err := newGRPCLogger(grpcLogCfg{CodeLevels: map[string]string{"Meow": "info"}, SlowLevel: "warn"})
+	assert.Error(t, err)
+
+	_, err = newGRPCLogger(grpcLogCfg{CodeLevels: map[string]string{"NotFound": "meow"}, SlowLevel: "warn"})
+	assert.Error(t, err)
+
+	g, err := newGRPCLogger(grpcLogCfg{CodeLevels: map[string]string{"notfound": "debug"}, SlowLevel: "warn"})
+	require.NoError(t, err)
+
+	assert.Equal(t, zapcore.DebugLevel, g.level(codes.NotFound))
+	assert.Equal(t, zapcore.ErrorLevel, g.level(codes.Internal))
+}
+
+func TestGRPCLogShouldLog(t *testing.T) {
+	g, err := newGRPCLogger(grpcLogCfg{Exclude: []string{"grpc.health.v1.Health/*"}, SlowLevel: "warn"})
+	require.NoError(t, err)
+
+	assert.False(t, g.shouldLog("/grpc.health.v1.Health/Check"))
+	assert.True(t, g.shouldLog("/cats.Cats/Meow"))
+
+	g.cfg.Include = []string{"dogs.*/*"}
+
+	assert.False(t, g.shouldLog("/cats.Cats/Meow"))
+	assert.True(t, g.shouldLog("/dogs.Dogs/Woof"))
+}
+
+func runGRPCLog(t *testing.T, cfg grpcLogCfg, handler grpc.UnaryHandler) []observer.LoggedEntry {
+	cfg.SlowLevel = "warn"
+
+	g, err := newGRPCLogger(cfg)
+	require.NoError(t, err)
+
+	core, logs := observer.New(zapcore.DebugLevel)
+
+	chain := []grpc.UnaryServerInterceptor{grpc_zap.UnaryServerInterceptor(zap.New(core), g.options()...)}
+	if cfg.Payloads {
+		chain = append(chain, g.payloadUnaryInterceptor)
+	}
+
+	info := &grpc.UnaryServerInfo{FullMethod: "/cats.Cats/Meow"}
+
+	h := handler
+	for i := len(chain) - 1; i >= 0; i-- {
+		next, ic := h, chain[i]
+		h = func(ctx context.Context, req interface{}) (interface{}, error) { return ic(ctx, req, info, next) }
+	}
+
+	_, _ = h(context.Background(), wrapperspb.String(strings.Repeat("meow", 10)))
+
+	return logs.AllUntimed()
+}
+
+func TestGRPCLogLevels(t *testing.T) {
+	logs := runGRPCLog(t, grpcLogCfg{}, func(context.Context, interface{}) (interface{}, error) {
+		return nil, status.Error(codes.Internal, "oops")
+	})
+
+	require.Len(t, logs, 1)
+	assert.Equal(t, zapcore.ErrorLevel, logs[0].Level)
+}
+
+func TestGRPCLogSlow(t *testing.T) {
+	logs := runGRPCLog(t, grpcLogCfg{SlowThreshold: time.Millisecond}, func(context.Context, interface{}) (interface{}, error) {
+		time.Sleep(5 * time.Millisecond)
+		return nil, nil
+	})
+
+	require.Len(t, logs, 1)
+	assert.Equal(t, zapcore.WarnLevel, logs[0].Level)
+	assert.Equal(t, true, logs[0].ContextMap()["grpc.slow"])
+
+	if ms, ok := logs[0].ContextMap()["grpc.time_ms"].(float32); assert.True(t, ok) {
+		assert.GreaterOrEqual(t, ms, float32(5))
+	}
+
+	logs = runGRPCLog(t, grpcLogCfg{SlowThreshold: time.Minute}, func(context.Context, interface{}) (interface{}, error) {
+		return nil, nil
+	})
+
+	require.Len(t, logs, 1)
+	assert.Equal(t, zapcore.InfoLevel, logs[0].Level)
+	assert.NotContains(t, logs[0].ContextMap(), "grpc.slow")
+}
+
+func TestGRPCLogPayloads(t *testing.T) {
+	logs := runGRPCLog(t, grpcLogCfg{Payloads: true, PayloadMaxSize: 10}, func(_ context.Context, req interface{}) (interface{}, error) {
+		return req, nil
+	})
+
+	require.Len(t, logs, 3)
+	assert.Equal(t, `"meowmeowm... (32 bytes truncated)`, logs[0].ContextMap()["grpc.request.content"])
+	assert.Contains(t, logs[1].ContextMap(), "grpc.response.content")
+	assert.Equal(t, zapcore.InfoLevel, logs[2].Level)
+}
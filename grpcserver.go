@@ -0,0 +1,80 @@
+package svc
+
+import (
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/keepalive"
+)
+
+// Zero values leave the GRPC defaults in place. See keepalive.ServerParameters
+// and keepalive.EnforcementPolicy.
+type grpcKeepaliveCfg struct {
+	MaxConnectionIdle     time.Duration `envconfig:"MAX_CONNECTION_IDLE" json:"max_connection_idle"`
+	MaxConnectionAge      time.Duration `envconfig:"MAX_CONNECTION_AGE" json:"max_connection_age"` // lets clients rebalance across load balanced servers.
+	MaxConnectionAgeGrace time.Duration `envconfig:"MAX_CONNECTION_AGE_GRACE" json:"max_connection_age_grace"`
+	Time                  time.Duration `envconfig:"TIME" json:"time"`
+	Timeout               time.Duration `envconfig:"TIMEOUT" json:"timeout"`
+
+	// Enforcement policy. Clients pinging more frequently than MinTime are
+	// disconnected.
+	MinTime             time.Duration `envconfig:"MIN_TIME" json:"min_time"`
+	PermitWithoutStream bool          `envconfig:"PERMIT_WITHOUT_STREAM" json:"permit_without_stream"`
+}
+
+func (c grpcKeepaliveCfg) serverOptions() []grpc.ServerOption {
+	var opts []grpc.ServerOption
+
+	params := keepalive.ServerParameters{
+		MaxConnectionIdle:     c.MaxConnectionIdle,
+		MaxConnectionAge:      c.MaxConnectionAge,
+		MaxConnectionAgeGrace: c.MaxConnectionAgeGrace,
+		Time:                  c.Time,
+		Timeout:               c.Timeout,
+	}
+
+	if params != (keepalive.ServerParameters{}) {
+		opts = append(opts, grpc.KeepaliveParams(params))
+	}
+
+	if c.MinTime > 0 || c.PermitWithoutStream {
+		opts = append(opts, grpc.KeepaliveEnforcementPolicy(keepalive.EnforcementPolicy{
+			MinTime:             c.MinTime,
+			PermitWithoutStream: c.PermitWithoutStream,
+		}))
+	}
+
+	return opts
+}
+
+// Server options derived from cfg. Transport options, such as keepalive and
+// window sizes, do not apply when GRPC is served on the HTTP server.
+func (c grpcCfg) serverOptions() []grpc.ServerOption {
+	var opts []grpc.ServerOption
+
+	if s := c.MaxSendMsgSize; s > 0 {
+		opts = append(opts, grpc.MaxSendMsgSize(s))
+	}
+
+	if s := c.MaxRecvMsgSize; s > 0 {
+		opts = append(opts, grpc.MaxRecvMsgSize(s))
+	}
+
+	if n := c.MaxConcurrentStreams; n > 0 {
+		opts = append(opts, grpc.MaxConcurrentStreams(n))
+	}
+
+	if t := c.ConnectionTimeout; t > 0 {
+		opts = append(opts, grpc.ConnectionTimeout(t))
+	}
+
+	if s := c.InitialWindowSize; s > 0 {
+		opts = append(opts, grpc.InitialWindowSize(s))
+	}
+
+	if s := c.InitialConnWindowSize; s > 0 {
+		opts = append(opts, grpc.InitialConnWindowSize(s))
+	}
+
+	return append(opts, c.Keepalive.serverOptions()...)
+}
@@ -0,0 +1,38 @@
+//go:build unit
+
+package svc
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+)
+
+func TestGRPCServerOptions(t *testing.T) {
+	assert.Empty(t, grpcCfg{}.serverOptions())
+
+	opts := grpcCfg{
+		MaxSendMsgSize:        1,
+		MaxRecvMsgSize:        1,
+		MaxConcurrentStreams:  1,
+		ConnectionTimeout:     time.Second,
+		InitialWindowSize:     1 << 16,
+		InitialConnWindowSize: 1 << 16,
+		Keepalive: grpcKeepaliveCfg{
+			MaxConnectionAge: time.Minute,
+			MinTime:          time.Second,
+		},
+	}.serverOptions()
+
+	assert.Len(t, opts, 8)
+
+	// options must not conflict.
+	grpc.NewServer(opts...).Stop()
+}
+
+func TestGRPCKeepaliveOptions(t *testing.T) {
+	assert.Len(t, grpcKeepaliveCfg{Time: time.Second}.serverOptions(), 1)
+	assert.Len(t, grpcKeepaliveCfg{PermitWithoutStream: true}.serverOptions(), 1)
+}
@@ -0,0 +1,297 @@
+package svc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+type HealthCheckKind int
+
+const (
+	// Liveness checks fail if the process should be restarted.
+	LivenessCheck HealthCheckKind = iota
+
+	// Readiness checks fail if the process should not receive traffic.
+	ReadinessCheck
+)
+
+func (k HealthCheckKind) String() string {
+	switch k {
+	case LivenessCheck:
+		return "liveness"
+	case ReadinessCheck:
+		return "readiness"
+	default:
+		return fmt.Sprintf("HealthCheckKind(%d)", int(k))
+	}
+}
+
+const defaultHealthCheckTimeout = 5 * time.Second
+
+type HealthCheck struct {
+	Name string
+	Kind HealthCheckKind
+
+	// If zero, defaults to 5 seconds.
+	Timeout time.Duration
+
+	// If non-zero, results are reused for this long.
+	CacheTTL time.Duration
+
+	// Readiness checks only: if specified, a failure marks only this GRPC
+	// service as not serving, rather than the whole server.
+	GRPCService string
+
+	Check func(context.Context) error
+}
+
+type healthResult struct {
+	at       time.Time
+	err      error
+	duration time.Duration
+}
+
+type healthEntry struct {
+	HealthCheck
+
+	mu   sync.Mutex
+	last *healthResult
+}
+
+func (e *healthEntry) run(ctx context.Context) healthResult {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.last != nil && e.CacheTTL > 0 && time.Since(e.last.at) < e.CacheTTL {
+		return *e.last
+	}
+
+	timeout := e.Timeout
+	if timeout == 0 {
+		timeout = defaultHealthCheckTimeout
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	t0 := time.Now()
+
+	r := healthResult{at: t0, err: e.Check(ctx)}
+	r.duration = time.Since(t0)
+
+	e.last = &r
+
+	return r
+}
+
+// Registry of health checks. Provided to all components starting with
+// the init phase.
+//
+// Readiness is reported as failing until all components are ready, and
+// again once shutting down.
+type HealthRegistry struct {
+	mu      sync.RWMutex
+	entries []*healthEntry
+	ready   bool
+}
+
+func (r *HealthRegistry) Register(c HealthCheck) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.entries = append(r.entries, &healthEntry{HealthCheck: c})
+}
+
+func (r *HealthRegistry) setReady(ready bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.ready = ready
+}
+
+// Result of a single check, as reported in detailed HTTP responses.
+type HealthCheckReport struct {
+	Name     string `json:"name"`
+	OK       bool   `json:"ok"`
+	Error    string `json:"error,omitempty"`
+	Duration string `json:"duration"`
+
+	grpcService string
+}
+
+type HealthReport struct {
+	OK     bool                `json:"ok"`
+	Error  string              `json:"error,omitempty"`
+	Checks []HealthCheckReport `json:"checks,omitempty"`
+}
+
+func (r *HealthRegistry) run(ctx context.Context, kind HealthCheckKind) HealthReport {
+	r.mu.RLock()
+	ready := r.ready
+	var entries []*healthEntry
+	for _, e := range r.entries {
+		if e.Kind == kind {
+			entries = append(entries, e)
+		}
+	}
+	r.mu.RUnlock()
+
+	report := HealthReport{OK: true, Checks: make([]HealthCheckReport, len(entries))}
+
+	if kind == ReadinessCheck && !ready {
+		report.OK, report.Error = false, "not ready"
+	}
+
+	var wg sync.WaitGroup
+
+	wg.Add(len(entries))
+
+	for i, e := range entries {
+		i, e := i, e
+
+		go func() {
+			defer wg.Done()
+
+			res := e.run(ctx)
+
+			report.Checks[i] = HealthCheckReport{
+				Name:        e.Name,
+				OK:          res.err == nil,
+				Duration:    res.duration.String(),
+				grpcService: e.GRPCService,
+			}
+
+			if res.err != nil {
+				report.Checks[i].Error = res.err.Error()
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	for _, c := range report.Checks {
+		if !c.OK && c.grpcService == "" {
+			report.OK = false
+		}
+	}
+
+	return report
+}
+
+// Returns nil if all liveness checks pass.
+func (r *HealthRegistry) Live(ctx context.Context) error {
+	return r.run(ctx, LivenessCheck).err()
+}
+
+// Returns nil if ready and all readiness checks pass.
+func (r *HealthRegistry) Ready(ctx context.Context) error {
+	return r.run(ctx, ReadinessCheck).err()
+}
+
+func (r HealthReport) err() error {
+	if r.OK {
+		return nil
+	}
+
+	if r.Error != "" {
+		return errors.New(r.Error)
+	}
+
+	for _, c := range r.Checks {
+		if !c.OK {
+			return fmt.Errorf("%s: %s", c.Name, c.Error)
+		}
+	}
+
+	return errors.New("unhealthy")
+}
+
+// Responds with 200 if all checks of kind pass, otherwise with 503.
+// If the "verbose" query parameter is specified, responds with a JSON
+// encoded HealthReport.
+func (r *HealthRegistry) handler(kind HealthCheckKind) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		report := r.run(req.Context(), kind)
+
+		code := http.StatusOK
+		if !report.OK {
+			code = http.StatusServiceUnavailable
+		}
+
+		if _, verbose := req.URL.Query()["verbose"]; !verbose {
+			w.Header().Set("Content-Type", "text/plain")
+			w.WriteHeader(code)
+
+			if report.OK {
+				_, _ = w.Write([]byte("ok\n"))
+			} else {
+				_, _ = fmt.Fprintf(w, "%v\n", report.err())
+			}
+
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(code)
+		_ = json.NewEncoder(w).Encode(report)
+	})
+}
+
+// Periodically update the GRPC health server with readiness status until
+// ctx is done. The overall server status is reported under the empty
+// service name, and for each of services.
+func (r *HealthRegistry) updateGRPC(ctx context.Context, hs *health.Server, services []string, interval time.Duration) {
+	update := func() {
+		report := r.run(ctx, ReadinessCheck)
+
+		status := func(ok bool) healthpb.HealthCheckResponse_ServingStatus {
+			if ok {
+				return healthpb.HealthCheckResponse_SERVING
+			}
+
+			return healthpb.HealthCheckResponse_NOT_SERVING
+		}
+
+		hs.SetServingStatus("", status(report.OK))
+
+		for _, s := range services {
+			ok := report.OK
+
+			for _, c := range report.Checks {
+				if c.grpcService == s && !c.OK {
+					ok = false
+				}
+			}
+
+			hs.SetServingStatus(s, status(ok))
+		}
+	}
+
+	update()
+
+	if interval <= 0 {
+		return
+	}
+
+	go func() {
+		t := time.NewTicker(interval)
+		defer t.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-t.C:
+				update()
+			}
+		}
+	}()
+}
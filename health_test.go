@@ -0,0 +1,110 @@
+//go:build unit
+
+package svc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+func TestHealthRegistry(t *testing.T) {
+	var (
+		r     HealthRegistry
+		dbErr error
+		calls int
+	)
+
+	r.Register(HealthCheck{
+		Name: "db",
+		Kind: ReadinessCheck,
+		Check: func(context.Context) error {
+			calls++
+			return dbErr
+		},
+		CacheTTL: time.Hour,
+	})
+
+	r.Register(HealthCheck{
+		Name:  "deadlock",
+		Kind:  LivenessCheck,
+		Check: func(context.Context) error { return nil },
+	})
+
+	ctx := context.Background()
+
+	assert.NoError(t, r.Live(ctx))
+	assert.EqualError(t, r.Ready(ctx), "not ready")
+
+	r.setReady(true)
+
+	assert.NoError(t, r.Ready(ctx))
+
+	// cached.
+	dbErr = errors.New("meow")
+	assert.NoError(t, r.Ready(ctx))
+	assert.Equal(t, 1, calls)
+}
+
+func TestHealthHandler(t *testing.T) {
+	var r HealthRegistry
+
+	r.Register(HealthCheck{
+		Name:  "db",
+		Kind:  ReadinessCheck,
+		Check: func(context.Context) error { return errors.New("meow") },
+	})
+
+	r.setReady(true)
+
+	w := httptest.NewRecorder()
+	r.handler(LivenessCheck).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w = httptest.NewRecorder()
+	r.handler(ReadinessCheck).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/readyz?verbose", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+
+	var report HealthReport
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&report))
+
+	if assert.Len(t, report.Checks, 1) {
+		assert.Equal(t, "meow", report.Checks[0].Error)
+	}
+}
+
+func TestHealthGRPC(t *testing.T) {
+	var r HealthRegistry
+
+	r.Register(HealthCheck{
+		Name:        "cats",
+		Kind:        ReadinessCheck,
+		GRPCService: "cats.Cats",
+		Check:       func(context.Context) error { return errors.New("meow") },
+	})
+
+	r.setReady(true)
+
+	hs := health.NewServer()
+
+	r.updateGRPC(context.Background(), hs, []string{"cats.Cats", "dogs.Dogs"}, 0)
+
+	check := func(s string) healthpb.HealthCheckResponse_ServingStatus {
+		resp, err := hs.Check(context.Background(), &healthpb.HealthCheckRequest{Service: s})
+		require.NoError(t, err)
+		return resp.Status
+	}
+
+	assert.Equal(t, healthpb.HealthCheckResponse_SERVING, check(""))
+	assert.Equal(t, healthpb.HealthCheckResponse_SERVING, check("dogs.Dogs"))
+	assert.Equal(t, healthpb.HealthCheckResponse_NOT_SERVING, check("cats.Cats"))
+}
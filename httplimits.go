@@ -0,0 +1,47 @@
+package svc
+
+import (
+	"net/http"
+)
+
+// Wraps h with the request limits in cfg. Returns the names of the added
+// middlewares, from the outermost.
+func withHTTPLimits(h http.Handler, cfg httpCfg) (http.Handler, []string) {
+	var chain []string
+
+	if cfg.HandlerTimeout > 0 {
+		// responds with 503 once the timeout elapses.
+		h = http.TimeoutHandler(h, cfg.HandlerTimeout, "handler timeout")
+		chain = append(chain, "handler-timeout")
+	}
+
+	if n := cfg.MaxBodyBytes; n > 0 {
+		next := h
+
+		h = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.ContentLength > n {
+				http.Error(w, http.StatusText(http.StatusRequestEntityTooLarge), http.StatusRequestEntityTooLarge)
+				return
+			}
+
+			r.Body = http.MaxBytesReader(w, r.Body, n)
+
+			next.ServeHTTP(w, r)
+		})
+
+		chain = append([]string{"max-body-bytes"}, chain...)
+	}
+
+	return h, chain
+}
+
+func newHTTPServer(h http.Handler, cfg httpCfg) *http.Server {
+	return &http.Server{
+		Handler:           h,
+		ReadTimeout:       cfg.ReadTimeout,
+		ReadHeaderTimeout: cfg.ReadHeaderTimeout,
+		WriteTimeout:      cfg.WriteTimeout,
+		IdleTimeout:       cfg.IdleTimeout,
+		MaxHeaderBytes:    cfg.MaxHeaderBytes,
+	}
+}
@@ -0,0 +1,65 @@
+//go:build unit
+
+package svc
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHTTPLimitsNone(t *testing.T) {
+	_, chain := withHTTPLimits(http.NotFoundHandler(), httpCfg{})
+	assert.Empty(t, chain)
+}
+
+func TestHTTPLimitsMaxBodyBytes(t *testing.T) {
+	h, chain := withHTTPLimits(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, err := io.ReadAll(r.Body); err != nil {
+			http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+		}
+	}), httpCfg{MaxBodyBytes: 4})
+
+	assert.Equal(t, []string{"max-body-bytes"}, chain)
+
+	do := func(body io.Reader, contentLength int64) int {
+		req := httptest.NewRequest(http.MethodPost, "/", body)
+		req.ContentLength = contentLength
+
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+
+		return rec.Code
+	}
+
+	assert.Equal(t, http.StatusOK, do(strings.NewReader("meow"), 4))
+	assert.Equal(t, http.StatusRequestEntityTooLarge, do(strings.NewReader("meoww"), 5))
+
+	// unknown length.
+	assert.Equal(t, http.StatusRequestEntityTooLarge, do(strings.NewReader("meoww"), -1))
+}
+
+func TestHTTPLimitsHandlerTimeout(t *testing.T) {
+	h, chain := withHTTPLimits(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}), httpCfg{HandlerTimeout: 10 * time.Millisecond, MaxBodyBytes: 1})
+
+	assert.Equal(t, []string{"max-body-bytes", "handler-timeout"}, chain)
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+}
+
+func TestNewHTTPServer(t *testing.T) {
+	srv := newHTTPServer(http.NotFoundHandler(), httpCfg{ReadHeaderTimeout: time.Second, MaxHeaderBytes: 10})
+
+	assert.Equal(t, time.Second, srv.ReadHeaderTimeout)
+	assert.Equal(t, 10, srv.MaxHeaderBytes)
+}
@@ -0,0 +1,69 @@
+package svc
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+)
+
+type httpMiddleware struct {
+	name     string
+	priority int
+	f        func(http.Handler) http.Handler
+}
+
+// HTTP middlewares added by components, applied to all routes of the HTTP
+// router. Can only be used by the Init and Setup phases: the chain is built
+// before the Start phase, after which Add panics.
+//
+// The complete chain, from the outermost, is:
+//  1. Panic recovery, for panics in the middlewares below.
+//  2. Peer identity (see PeerIdentityFromContext).
+//  3. GRPC, if served on the HTTP server. GRPC requests do not continue.
+//  4. CORS, if configured.
+//  5. Request ID (see RequestIDFromContext).
+//  6. Access log.
+//  7. Body size limit, if configured.
+//  8. Handler timeout, if configured.
+//  9. Routing. Requests that do not match any route do not continue.
+//  10. Tracing, if enabled.
+//  11. Metrics, if enabled.
+//  12. Rate limiting, if enabled.
+//  13. Panic recovery, so the middlewares above observe recovered requests
+//     as failed with 500.
+//  14. Component middlewares, by ascending priority. Middlewares with the
+//     same priority are in the order added.
+//
+// The chain is logged at debug level when the HTTP server starts.
+type HTTPMiddlewares struct {
+	mu     sync.Mutex
+	ms     []httpMiddleware
+	frozen bool
+}
+
+func (m *HTTPMiddlewares) Add(name string, priority int, f func(http.Handler) http.Handler) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.frozen {
+		panic(fmt.Sprintf("http middleware %q added after the chain was built", name))
+	}
+
+	m.ms = append(m.ms, httpMiddleware{name: name, priority: priority, f: f})
+}
+
+// Returns the middlewares in chain order. Once called, Add panics.
+func (m *HTTPMiddlewares) sorted() []httpMiddleware {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.frozen = true
+
+	ms := make([]httpMiddleware, len(m.ms))
+	copy(ms, m.ms)
+
+	sort.SliceStable(ms, func(i, j int) bool { return ms[i].priority < ms[j].priority })
+
+	return ms
+}
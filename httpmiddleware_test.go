@@ -0,0 +1,54 @@
+//go:build unit
+
+package svc
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHTTPMiddlewaresOrder(t *testing.T) {
+	var (
+		ms    HTTPMiddlewares
+		trail []string
+	)
+
+	add := func(name string, priority int) {
+		ms.Add(name, priority, func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				trail = append(trail, name)
+				next.ServeHTTP(w, r)
+			})
+		})
+	}
+
+	add("c", 10)
+	add("a", 0)
+	add("b", 0)
+	add("first", -1)
+
+	r := mux.NewRouter()
+	for _, mw := range ms.sorted() {
+		r.Use(mw.f)
+	}
+
+	r.HandleFunc("/", func(http.ResponseWriter, *http.Request) { trail = append(trail, "handler") })
+
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+
+	assert.Equal(t, []string{"first", "a", "b", "c", "handler"}, trail)
+}
+
+func TestHTTPMiddlewaresFrozen(t *testing.T) {
+	var ms HTTPMiddlewares
+
+	ms.Add("a", 0, func(h http.Handler) http.Handler { return h })
+
+	assert.Len(t, ms.sorted(), 1)
+
+	assert.Panics(t, func() { ms.Add("b", 0, func(h http.Handler) http.Handler { return h }) })
+}
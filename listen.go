@@ -0,0 +1,166 @@
+package svc
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/user"
+	"strconv"
+	"strings"
+)
+
+// Addresses with this prefix are unix domain sockets, for example
+// "unix:///run/svc/grpc.sock".
+const unixAddrPrefix = "unix://"
+
+// Returns the addresses to listen on. If addrs are specified, they are used
+// as is. Otherwise, host and port are combined into a single address. An empty
+// host means all interfaces.
+//
+// IPv6 hosts are specified without brackets, for example "::1". IPv6 addrs
+// are specified with brackets, for example "[::1]:20000".
+func listenAddrs(host string, port int, addrs []string) []string {
+	if len(addrs) != 0 {
+		return addrs
+	}
+
+	return []string{net.JoinHostPort(host, strconv.Itoa(port))}
+}
+
+// Listen on all addrs. If any of them fail, all already opened listeners
+// are closed.
+func listen(addrs []string, unix unixSocketCfg) ([]net.Listener, error) {
+	liss := make([]net.Listener, 0, len(addrs))
+
+	for _, addr := range addrs {
+		lis, err := listenOne(addr, unix)
+		if err != nil {
+			for _, lis := range liss {
+				lis.Close()
+			}
+
+			return nil, fmt.Errorf("listen %q: %w", addr, err)
+		}
+
+		liss = append(liss, lis)
+	}
+
+	return liss, nil
+}
+
+func listenOne(addr string, unix unixSocketCfg) (net.Listener, error) {
+	if !strings.HasPrefix(addr, unixAddrPrefix) {
+		return net.Listen("tcp", addr)
+	}
+
+	path := strings.TrimPrefix(addr, unixAddrPrefix)
+
+	if err := removeStaleSocket(path); err != nil {
+		return nil, err
+	}
+
+	// The socket file is removed when the listener is closed.
+	lis, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := unix.apply(path); err != nil {
+		lis.Close()
+		return nil, err
+	}
+
+	return lis, nil
+}
+
+// Remove a socket file left over by a previous process. Fails if the
+// socket is still in use or if path is not a socket.
+func removeStaleSocket(path string) error {
+	fi, err := os.Lstat(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+
+		return err
+	}
+
+	if fi.Mode()&os.ModeSocket == 0 {
+		return fmt.Errorf("%q exists and is not a socket", path)
+	}
+
+	if conn, err := net.Dial("unix", path); err == nil {
+		conn.Close()
+		return fmt.Errorf("%q is in use", path)
+	}
+
+	return os.Remove(path)
+}
+
+func (c unixSocketCfg) apply(path string) error {
+	if c.Mode != "" {
+		mode, err := strconv.ParseUint(c.Mode, 8, 32)
+		if err != nil {
+			return fmt.Errorf("invalid mode %q: %w", c.Mode, err)
+		}
+
+		if err := os.Chmod(path, os.FileMode(mode)); err != nil {
+			return err
+		}
+	}
+
+	if c.Owner == "" && c.Group == "" {
+		return nil
+	}
+
+	uid, gid := -1, -1
+
+	if c.Owner != "" {
+		id, err := lookupID(c.Owner, func(n string) (string, error) {
+			u, err := user.Lookup(n)
+			if err != nil {
+				return "", err
+			}
+
+			return u.Uid, nil
+		})
+		if err != nil {
+			return fmt.Errorf("owner %q: %w", c.Owner, err)
+		}
+
+		uid = id
+	}
+
+	if c.Group != "" {
+		id, err := lookupID(c.Group, func(n string) (string, error) {
+			g, err := user.LookupGroup(n)
+			if err != nil {
+				return "", err
+			}
+
+			return g.Gid, nil
+		})
+		if err != nil {
+			return fmt.Errorf("group %q: %w", c.Group, err)
+		}
+
+		gid = id
+	}
+
+	return os.Lchown(path, uid, gid)
+}
+
+// Returns n as a numeric id if it is one, otherwise looks it up by name.
+func lookupID(n string, lookup func(string) (string, error)) (int, error) {
+	if id, err := strconv.Atoi(n); err == nil {
+		return id, nil
+	}
+
+	sid, err := lookup(n)
+	if err != nil {
+		return 0, err
+	}
+
+	return strconv.Atoi(sid)
+}
@@ -0,0 +1,59 @@
+//go:build unit
+
+package svc
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestListenAddrs(t *testing.T) {
+	assert.Equal(t, []string{":20000"}, listenAddrs("", 20000, nil))
+	assert.Equal(t, []string{"[::1]:20000"}, listenAddrs("::1", 20000, nil))
+	assert.Equal(t, []string{"a:1", "b:2"}, listenAddrs("::1", 20000, []string{"a:1", "b:2"}))
+}
+
+func TestListenUnix(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.sock")
+	addr := unixAddrPrefix + path
+
+	liss, err := listen([]string{addr}, unixSocketCfg{Mode: "0600"})
+	require.NoError(t, err)
+
+	assert.Equal(t, path, liss[0].Addr().String())
+
+	fi, err := os.Stat(path)
+	if assert.NoError(t, err) {
+		assert.Equal(t, os.FileMode(0o600), fi.Mode().Perm())
+	}
+
+	// in use.
+	_, err = listen([]string{addr}, unixSocketCfg{})
+	assert.Error(t, err)
+
+	liss[0].Close()
+
+	_, err = os.Stat(path)
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestListenUnixStale(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.sock")
+
+	lis, err := net.Listen("unix", path)
+	require.NoError(t, err)
+
+	// leave the socket file behind.
+	lis.(*net.UnixListener).SetUnlinkOnClose(false)
+	lis.Close()
+
+	liss, err := listen([]string{unixAddrPrefix + path}, unixSocketCfg{})
+	if assert.NoError(t, err) {
+		liss[0].Close()
+	}
+}
@@ -0,0 +1,342 @@
+package svc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
+
+	"github.com/autokitteh/L"
+	"github.com/autokitteh/L/Z"
+)
+
+type namedLevel struct {
+	level zapcore.Level
+	timer *time.Timer // nil if no TTL.
+}
+
+// Log levels that can be changed at runtime, both globally and for
+// individual named loggers, such as the per component loggers or "grpc",
+// "grpc-stream" and "http". A named logger level also applies to loggers
+// named under it, so "db" also controls "db.pool".
+//
+// Provided to all components starting with the init phase, unless the
+// logger was set using WithLogger.
+type LogLevels struct {
+	initial zapcore.Level
+
+	mu    sync.RWMutex
+	named map[string]*namedLevel // global level is under "".
+}
+
+func newLogLevels(initial zapcore.Level) *LogLevels {
+	return &LogLevels{
+		initial: initial,
+		named:   map[string]*namedLevel{"": {level: initial}},
+	}
+}
+
+// Create a logger according to cfg, which is checked against the returned
+// runtime levels rather than the configured level.
+func newLeveledLogger(cfg Z.Config) (L.L, *LogLevels, error) {
+	var levels *LogLevels
+
+	zopts := append([]zap.Option{}, Z.DefaultOpts...)
+
+	if cfg.ErrorStackTrace {
+		zopts = append(zopts, zap.AddStacktrace(zapcore.ErrorLevel))
+	}
+
+	zopts = append(zopts, zap.WrapCore(func(c zapcore.Core) zapcore.Core { return &levelsCore{Core: c, levels: levels} }))
+
+	l, err := Z.NewL(cfg, func(zcfg *zap.Config) {
+		levels = newLogLevels(zcfg.Level.Level())
+
+		// levels decide what gets logged.
+		zcfg.Level = zap.NewAtomicLevelAt(zapcore.DebugLevel)
+	}, zopts)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return l, levels, nil
+}
+
+// Returns the level of logger, which is the global level if logger is empty
+// or no level is set for it or any of its parents.
+func (ls *LogLevels) Level(logger string) zapcore.Level {
+	ls.mu.RLock()
+	defer ls.mu.RUnlock()
+
+	for {
+		if n := ls.named[logger]; n != nil {
+			return n.level
+		}
+
+		i := strings.LastIndex(logger, ".")
+		if i < 0 {
+			return ls.named[""].level
+		}
+
+		logger = logger[:i]
+	}
+}
+
+// Set the level of logger, or the global level if logger is empty. If ttl
+// is non-zero, the previous level is restored once it elapses, unless set
+// again in the meantime.
+func (ls *LogLevels) Set(logger string, level zapcore.Level, ttl time.Duration) {
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+
+	prev := ls.named[logger]
+	if prev != nil && prev.timer != nil {
+		prev.timer.Stop()
+	}
+
+	n := &namedLevel{level: level}
+	ls.named[logger] = n
+
+	if ttl <= 0 {
+		return
+	}
+
+	n.timer = time.AfterFunc(ttl, func() {
+		ls.mu.Lock()
+		defer ls.mu.Unlock()
+
+		if ls.named[logger] != n {
+			return
+		}
+
+		if prev == nil {
+			delete(ls.named, logger)
+		} else {
+			ls.named[logger] = &namedLevel{level: prev.level}
+		}
+	})
+}
+
+// Remove the level set for logger, so it uses its parent level. If logger
+// is empty, the global level is restored to the configured level.
+func (ls *LogLevels) Reset(logger string) {
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+
+	if n := ls.named[logger]; n != nil && n.timer != nil {
+		n.timer.Stop()
+	}
+
+	if logger == "" {
+		ls.named[""] = &namedLevel{level: ls.initial}
+	} else {
+		delete(ls.named, logger)
+	}
+}
+
+type LogLevelsReport struct {
+	Level   string            `json:"level"`
+	Loggers map[string]string `json:"loggers,omitempty"`
+}
+
+func (ls *LogLevels) report() LogLevelsReport {
+	ls.mu.RLock()
+	defer ls.mu.RUnlock()
+
+	r := LogLevelsReport{Level: ls.named[""].level.String()}
+
+	for n, l := range ls.named {
+		if n == "" {
+			continue
+		}
+
+		if r.Loggers == nil {
+			r.Loggers = make(map[string]string, len(ls.named))
+		}
+
+		r.Loggers[n] = l.level.String()
+	}
+
+	return r
+}
+
+// Enabled if enabled for any logger, the actual decision is in Check where
+// the logger name is known.
+func (ls *LogLevels) anyEnabled(level zapcore.Level) bool {
+	ls.mu.RLock()
+	defer ls.mu.RUnlock()
+
+	for _, n := range ls.named {
+		if n.level.Enabled(level) {
+			return true
+		}
+	}
+
+	return false
+}
+
+type levelsCore struct {
+	zapcore.Core
+	levels *LogLevels
+}
+
+func (c *levelsCore) Enabled(level zapcore.Level) bool { return c.levels.anyEnabled(level) }
+
+func (c *levelsCore) With(fields []zapcore.Field) zapcore.Core {
+	return &levelsCore{Core: c.Core.With(fields), levels: c.levels}
+}
+
+func (c *levelsCore) Check(e zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if !c.levels.Level(e.LoggerName).Enabled(e.Level) {
+		return ce
+	}
+
+	return c.Core.Check(e, ce)
+}
+
+type setLogLevelRequest struct {
+	Logger string `json:"logger"`
+	Level  string `json:"level"`
+	TTL    string `json:"ttl"` // optional, as time.ParseDuration.
+}
+
+func (ls *LogLevels) set(req setLogLevelRequest) error {
+	var level zapcore.Level
+	if err := level.UnmarshalText([]byte(req.Level)); err != nil {
+		return fmt.Errorf("level: %w", err)
+	}
+
+	var ttl time.Duration
+
+	if req.TTL != "" {
+		var err error
+		if ttl, err = time.ParseDuration(req.TTL); err != nil {
+			return fmt.Errorf("ttl: %w", err)
+		}
+	}
+
+	ls.Set(req.Logger, level, ttl)
+
+	return nil
+}
+
+// GET responds with a LogLevelsReport.
+// PUT with {"logger": "grpc", "level": "debug", "ttl": "5m"} sets a level.
+// Logger and ttl are optional, so the global level is set by {"level": "debug"}.
+// DELETE with a "logger" query parameter resets a named logger level, or
+// the global level if not specified.
+func (ls *LogLevels) handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+		case http.MethodPut, http.MethodPost:
+			var req setLogLevelRequest
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+
+			if err := ls.set(req); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		case http.MethodDelete:
+			ls.Reset(r.URL.Query().Get("logger"))
+		default:
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		writeJSON(w, ls.report())
+	})
+}
+
+// GRPC service to view and change log levels, using google.protobuf.Struct
+// messages with the same fields as the admin HTTP endpoint:
+//
+//	svc.LogLevels/Get: {} -> {"level": "info", "loggers": {"grpc": "debug"}}
+//	svc.LogLevels/Set: {"logger": "grpc", "level": "debug", "ttl": "5m"} -> same as Get.
+const logLevelsServiceName = "svc.LogLevels"
+
+type logLevelsServer interface {
+	get(context.Context, *structpb.Struct) (*structpb.Struct, error)
+	set(context.Context, *structpb.Struct) (*structpb.Struct, error)
+}
+
+type logLevelsGRPC struct{ levels *LogLevels }
+
+func (s logLevelsGRPC) get(context.Context, *structpb.Struct) (*structpb.Struct, error) {
+	return s.reportStruct()
+}
+
+func (s logLevelsGRPC) set(_ context.Context, in *structpb.Struct) (*structpb.Struct, error) {
+	fs := in.GetFields()
+
+	req := setLogLevelRequest{
+		Logger: fs["logger"].GetStringValue(),
+		Level:  fs["level"].GetStringValue(),
+		TTL:    fs["ttl"].GetStringValue(),
+	}
+
+	if err := s.levels.set(req); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	return s.reportStruct()
+}
+
+func (s logLevelsGRPC) reportStruct() (*structpb.Struct, error) {
+	r := s.levels.report()
+
+	loggers := make(map[string]interface{}, len(r.Loggers))
+	for n, l := range r.Loggers {
+		loggers[n] = l
+	}
+
+	return structpb.NewStruct(map[string]interface{}{"level": r.Level, "loggers": loggers})
+}
+
+func logLevelsMethod(name string, f func(logLevelsServer, context.Context, *structpb.Struct) (*structpb.Struct, error)) grpc.MethodDesc {
+	return grpc.MethodDesc{
+		MethodName: name,
+		Handler: func(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+			in := new(structpb.Struct)
+			if err := dec(in); err != nil {
+				return nil, err
+			}
+
+			if interceptor == nil {
+				return f(srv.(logLevelsServer), ctx, in)
+			}
+
+			info := &grpc.UnaryServerInfo{Server: srv, FullMethod: "/" + logLevelsServiceName + "/" + name}
+
+			return interceptor(ctx, in, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+				return f(srv.(logLevelsServer), ctx, req.(*structpb.Struct))
+			})
+		},
+	}
+}
+
+var logLevelsServiceDesc = grpc.ServiceDesc{
+	ServiceName: logLevelsServiceName,
+	HandlerType: (*logLevelsServer)(nil),
+	Methods: []grpc.MethodDesc{
+		logLevelsMethod("Get", logLevelsServer.get),
+		logLevelsMethod("Set", logLevelsServer.set),
+	},
+}
+
+func registerLogLevelsGRPC(srv *grpc.Server, levels *LogLevels) {
+	srv.RegisterService(&logLevelsServiceDesc, logLevelsGRPC{levels: levels})
+}
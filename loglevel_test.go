@@ -0,0 +1,120 @@
+//go:build unit
+
+package svc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+func newObservedLogger(levels *LogLevels) (*zap.Logger, *observer.ObservedLogs) {
+	core, logs := observer.New(zapcore.DebugLevel)
+	return zap.New(&levelsCore{Core: core, levels: levels}), logs
+}
+
+func TestLogLevelsNamed(t *testing.T) {
+	levels := newLogLevels(zapcore.InfoLevel)
+	z, logs := newObservedLogger(levels)
+
+	z.Debug("root")
+	z.Named("db").Debug("db")
+	assert.Equal(t, 0, logs.Len())
+
+	levels.Set("db", zapcore.DebugLevel, 0)
+
+	z.Debug("root")
+	z.Named("db").Debug("db")
+	z.Named("db").Named("pool").Debug("db.pool")
+	z.Named("http").Debug("http")
+
+	var msgs []string
+	for _, e := range logs.TakeAll() {
+		msgs = append(msgs, e.Message)
+	}
+
+	assert.Equal(t, []string{"db", "db.pool"}, msgs)
+
+	levels.Set("db.pool", zapcore.ErrorLevel, 0)
+	assert.Equal(t, zapcore.ErrorLevel, levels.Level("db.pool.x"))
+	assert.Equal(t, zapcore.DebugLevel, levels.Level("db"))
+
+	levels.Reset("db")
+	assert.Equal(t, zapcore.InfoLevel, levels.Level("db"))
+
+	levels.Set("", zapcore.WarnLevel, 0)
+	assert.Equal(t, zapcore.WarnLevel, levels.Level("http"))
+
+	levels.Reset("")
+	assert.Equal(t, zapcore.InfoLevel, levels.Level("http"))
+}
+
+func TestLogLevelsTTL(t *testing.T) {
+	levels := newLogLevels(zapcore.InfoLevel)
+
+	levels.Set("api", zapcore.DebugLevel, 10*time.Millisecond)
+	assert.Equal(t, zapcore.DebugLevel, levels.Level("api"))
+
+	assert.Eventually(t, func() bool { return levels.Level("api") == zapcore.InfoLevel }, time.Second, 5*time.Millisecond)
+
+	// a later set is not reverted by an earlier TTL.
+	levels.Set("api", zapcore.DebugLevel, 10*time.Millisecond)
+	levels.Set("api", zapcore.WarnLevel, 0)
+
+	time.Sleep(30 * time.Millisecond)
+	assert.Equal(t, zapcore.WarnLevel, levels.Level("api"))
+}
+
+func TestLogLevelsHandler(t *testing.T) {
+	levels := newLogLevels(zapcore.InfoLevel)
+	h := levels.handler()
+
+	do := func(method, path, body string) (int, LogLevelsReport) {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(method, path, strings.NewReader(body)))
+
+		var r LogLevelsReport
+		_ = json.NewDecoder(bytes.NewReader(rec.Body.Bytes())).Decode(&r)
+
+		return rec.Code, r
+	}
+
+	code, r := do(http.MethodPut, "/loglevel", `{"logger": "http", "level": "debug"}`)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, LogLevelsReport{Level: "info", Loggers: map[string]string{"http": "debug"}}, r)
+
+	code, _ = do(http.MethodPut, "/loglevel", `{"level": "meow"}`)
+	assert.Equal(t, http.StatusBadRequest, code)
+
+	_, r = do(http.MethodDelete, "/loglevel?logger=http", "")
+	assert.Equal(t, LogLevelsReport{Level: "info"}, r)
+}
+
+func TestLogLevelsGRPC(t *testing.T) {
+	s := logLevelsGRPC{levels: newLogLevels(zapcore.InfoLevel)}
+
+	in, err := structpb.NewStruct(map[string]interface{}{"logger": "grpc", "level": "debug", "ttl": "1m"})
+	require.NoError(t, err)
+
+	out, err := s.set(context.Background(), in)
+	require.NoError(t, err)
+
+	assert.Equal(t, "info", out.Fields["level"].GetStringValue())
+	assert.Equal(t, "debug", out.Fields["loggers"].GetStructValue().Fields["grpc"].GetStringValue())
+
+	in.Fields["level"] = structpb.NewStringValue("meow")
+	_, err = s.set(context.Background(), in)
+	assert.Error(t, err)
+}
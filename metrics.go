@@ -0,0 +1,172 @@
+package svc
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/felixge/httpsnoop"
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+)
+
+// Built in metrics. The registry is provided to all components starting
+// with the init phase as *prometheus.Registry, which can also be requested
+// as prometheus.Registerer or prometheus.Gatherer.
+type metrics struct {
+	reg *prometheus.Registry
+
+	httpDuration  *prometheus.HistogramVec
+	grpcHandled   *prometheus.CounterVec
+	grpcDuration  *prometheus.HistogramVec
+	phaseDuration *prometheus.GaugeVec
+	panics        *prometheus.CounterVec
+	rateLimited   *prometheus.CounterVec
+}
+
+func newMetrics() *metrics {
+	m := metrics{
+		reg: prometheus.NewRegistry(),
+		httpDuration: prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name: "http_request_duration_seconds",
+				Help: "Duration of HTTP requests by route template.",
+			},
+			[]string{"route", "method", "code"},
+		),
+		grpcHandled: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "grpc_server_handled_total",
+				Help: "Total number of GRPC calls completed on the server.",
+			},
+			[]string{"grpc_type", "grpc_service", "grpc_method", "grpc_code"},
+		),
+		grpcDuration: prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name: "grpc_server_handling_seconds",
+				Help: "Duration of GRPC calls on the server.",
+			},
+			[]string{"grpc_type", "grpc_service", "grpc_method"},
+		),
+		phaseDuration: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "svc_startup_phase_duration_seconds",
+				Help: "Duration of each startup phase.",
+			},
+			[]string{"phase"},
+		),
+		panics: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "svc_panics_recovered_total",
+				Help: "Total number of panics recovered in HTTP handlers and GRPC methods.",
+			},
+			[]string{"kind"},
+		),
+		rateLimited: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "svc_rate_limited_total",
+				Help: "Total number of HTTP requests and GRPC calls rejected by rate limiting.",
+			},
+			[]string{"kind", "rule"},
+		),
+	}
+
+	m.reg.MustRegister(
+		m.httpDuration,
+		m.grpcHandled,
+		m.grpcDuration,
+		m.phaseDuration,
+		m.panics,
+		m.rateLimited,
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+
+	if v := GetVersion(); v != nil {
+		m.reg.MustRegister(prometheus.NewGaugeFunc(
+			prometheus.GaugeOpts{
+				Name:        "build_info",
+				Help:        "Build information, always 1.",
+				ConstLabels: prometheus.Labels{"version": v.Version, "commit": v.Commit, "date": v.Date},
+			},
+			func() float64 { return 1 },
+		))
+	}
+
+	return &m
+}
+
+func (m *metrics) handler() http.Handler {
+	return promhttp.HandlerFor(m.reg, promhttp.HandlerOpts{})
+}
+
+// Returns a function that records the duration of phase when called.
+func (m *metrics) phase(phase string) func() {
+	t0 := time.Now()
+
+	return func() { m.phaseDuration.WithLabelValues(phase).Set(time.Since(t0).Seconds()) }
+}
+
+// Mux middleware, so only requests that match a route are recorded.
+func (m *metrics) httpMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		route := ""
+		if cr := mux.CurrentRoute(r); cr != nil {
+			route, _ = cr.GetPathTemplate()
+		}
+
+		mt := httpsnoop.CaptureMetrics(next, w, r)
+
+		m.httpDuration.WithLabelValues(route, r.Method, strconv.Itoa(mt.Code)).Observe(mt.Duration.Seconds())
+	})
+}
+
+func splitGRPCMethod(fullMethod string) (string, string) {
+	fullMethod = strings.TrimPrefix(fullMethod, "/")
+
+	if i := strings.Index(fullMethod, "/"); i >= 0 {
+		return fullMethod[:i], fullMethod[i+1:]
+	}
+
+	return "unknown", fullMethod
+}
+
+func (m *metrics) observeGRPC(typ, fullMethod string, t0 time.Time, err error) {
+	service, method := splitGRPCMethod(fullMethod)
+
+	m.grpcHandled.WithLabelValues(typ, service, method, status.Code(err).String()).Inc()
+	m.grpcDuration.WithLabelValues(typ, service, method).Observe(time.Since(t0).Seconds())
+}
+
+func (m *metrics) unaryInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	t0 := time.Now()
+
+	resp, err := handler(ctx, req)
+
+	m.observeGRPC("unary", info.FullMethod, t0, err)
+
+	return resp, err
+}
+
+func (m *metrics) streamInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	t0 := time.Now()
+
+	err := handler(srv, ss)
+
+	typ := "bidi_stream"
+	if !info.IsClientStream {
+		typ = "server_stream"
+	} else if !info.IsServerStream {
+		typ = "client_stream"
+	}
+
+	m.observeGRPC(typ, info.FullMethod, t0, err)
+
+	return err
+}
@@ -0,0 +1,32 @@
+//go:build unit
+
+package svc
+
+import (
+	"context"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestSplitGRPCMethod(t *testing.T) {
+	s, m := splitGRPCMethod("/cats.Cats/Meow")
+	assert.Equal(t, "cats.Cats", s)
+	assert.Equal(t, "Meow", m)
+}
+
+func TestMetricsGRPC(t *testing.T) {
+	m := newMetrics()
+
+	info := &grpc.UnaryServerInfo{FullMethod: "/cats.Cats/Meow"}
+
+	_, _ = m.unaryInterceptor(context.Background(), nil, info, func(context.Context, interface{}) (interface{}, error) {
+		return nil, status.Error(codes.NotFound, "no cats")
+	})
+
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.grpcHandled.WithLabelValues("unary", "cats.Cats", "Meow", "NotFound")))
+}
@@ -0,0 +1,130 @@
+package svc
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/autokitteh/L"
+)
+
+// Notifies the service manager about state changes using the sd_notify
+// protocol (see sd_notify(3)). All methods are no-ops if the process was
+// not started with NOTIFY_SOCKET set.
+type notifier struct {
+	l    L.L
+	addr *net.UnixAddr
+
+	// zero if watchdog is not enabled.
+	watchdogInterval time.Duration
+}
+
+// The protocol environment variables are unset, so they will not be
+// inherited by child processes.
+func newNotifierFromEnv(l L.L) (*notifier, error) {
+	path, usec, pid := os.Getenv("NOTIFY_SOCKET"), os.Getenv("WATCHDOG_USEC"), os.Getenv("WATCHDOG_PID")
+
+	os.Unsetenv("NOTIFY_SOCKET")
+	os.Unsetenv("WATCHDOG_USEC")
+	os.Unsetenv("WATCHDOG_PID")
+
+	n := newNotifier(l, path)
+
+	if n.addr == nil || usec == "" {
+		return n, nil
+	}
+
+	if pid != "" && pid != strconv.Itoa(os.Getpid()) {
+		// meant for another process.
+		return n, nil
+	}
+
+	v, err := strconv.ParseInt(usec, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid WATCHDOG_USEC %q: %w", usec, err)
+	}
+
+	// recommended by sd_watchdog_enabled(3).
+	n.watchdogInterval = time.Duration(v) * time.Microsecond / 2
+
+	return n, nil
+}
+
+func newNotifier(l L.L, path string) *notifier {
+	n := notifier{l: l}
+
+	if path == "" {
+		return &n
+	}
+
+	// abstract namespace socket.
+	if strings.HasPrefix(path, "@") {
+		path = "\x00" + path[1:]
+	}
+
+	n.addr = &net.UnixAddr{Name: path, Net: "unixgram"}
+
+	return &n
+}
+
+func (n *notifier) notify(state string) {
+	if n.addr == nil {
+		return
+	}
+
+	conn, err := net.DialUnix("unixgram", nil, n.addr)
+	if err != nil {
+		n.l.Warn("notify dial error", "err", err)
+		return
+	}
+
+	defer conn.Close()
+
+	if _, err := conn.Write([]byte(state)); err != nil {
+		n.l.Warn("notify write error", "err", err)
+	}
+}
+
+func (n *notifier) ready()               { n.notify("READY=1") }
+func (n *notifier) stopping()            { n.notify("STOPPING=1") }
+func (n *notifier) status(status string) { n.notify("STATUS=" + status) }
+
+// Periodically send keep alive pings to the service manager, as long as
+// check succeeds, until ctx is done. Does nothing if watchdog is not enabled.
+func (n *notifier) watchdog(ctx context.Context, check func(context.Context) error) {
+	if n.addr == nil || n.watchdogInterval == 0 {
+		return
+	}
+
+	n.l.Debug("watchdog enabled", "interval", n.watchdogInterval)
+
+	go func() {
+		t := time.NewTicker(n.watchdogInterval)
+		defer t.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-t.C:
+			}
+
+			if check != nil {
+				ctx, cancel := context.WithTimeout(ctx, n.watchdogInterval)
+				err := check(ctx)
+				cancel()
+
+				if err != nil {
+					n.l.Warn("watchdog check failed", "err", err)
+					continue
+				}
+			}
+
+			n.notify("WATCHDOG=1")
+		}
+	}()
+}
@@ -0,0 +1,88 @@
+//go:build unit
+
+package svc
+
+import (
+	"context"
+	"errors"
+	"net"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/autokitteh/L"
+)
+
+func listenNotify(t *testing.T) (*net.UnixConn, string) {
+	path := filepath.Join(t.TempDir(), "notify.sock")
+
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	require.NoError(t, err)
+
+	t.Cleanup(func() { conn.Close() })
+
+	return conn, path
+}
+
+func readNotify(t *testing.T, conn *net.UnixConn) string {
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(time.Second)))
+
+	buf := make([]byte, 1024)
+
+	n, err := conn.Read(buf)
+	require.NoError(t, err)
+
+	return string(buf[:n])
+}
+
+func TestNotifier(t *testing.T) {
+	conn, path := listenNotify(t)
+
+	n := newNotifier(L.Nop, path)
+
+	n.status("starting")
+	assert.Equal(t, "STATUS=starting", readNotify(t, conn))
+
+	n.ready()
+	assert.Equal(t, "READY=1", readNotify(t, conn))
+
+	n.stopping()
+	assert.Equal(t, "STOPPING=1", readNotify(t, conn))
+}
+
+func TestNotifierWatchdog(t *testing.T) {
+	conn, path := listenNotify(t)
+
+	n := newNotifier(L.Nop, path)
+	n.watchdogInterval = 10 * time.Millisecond
+
+	var fail int32
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	n.watchdog(ctx, func(context.Context) error {
+		if atomic.AddInt32(&fail, 1) == 1 {
+			return errors.New("not yet")
+		}
+
+		return nil
+	})
+
+	assert.Equal(t, "WATCHDOG=1", readNotify(t, conn))
+
+	// first check failed, so at least two checks were made.
+	assert.GreaterOrEqual(t, atomic.LoadInt32(&fail), int32(2))
+}
+
+func TestNotifierDisabled(t *testing.T) {
+	n := newNotifier(L.Nop, "")
+
+	// should not panic or block.
+	n.ready()
+	n.watchdog(context.Background(), nil)
+}
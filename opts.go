@@ -1,6 +1,9 @@
 package svc
 
 import (
+	"context"
+	"net"
+
 	"github.com/autokitteh/L"
 )
 
@@ -21,6 +24,7 @@ type Flags struct {
 	ConfigPath                                      string
 	Enables, Disables, Onlys, Excepts               []string
 	Setup, HelpConfig, PrintConfig, ExitBeforeStart bool
+	ListComponents                                  bool
 }
 
 type opts struct {
@@ -30,6 +34,12 @@ type opts struct {
 	providers                     []interface{}
 	grpc, http                    bool
 	defaultDisables               []string
+	tags                          map[string][]string
+	listeners                     map[string][]net.Listener
+	watchdogCheck                 func(context.Context) error
+	panicHook                     PanicHook
+	rateLimitKey                  RateLimitKeyFunc
+	signalShutdown                bool
 	flags                         *Flags
 	l                             func() L.L
 
@@ -47,6 +57,11 @@ func WithConfig(cfg interface{}) OptFunc { return func(c *opts) { c.cfgs = appen
 // Replace command line flags with Flags.
 func WithFlags(f *Flags) OptFunc { return func(c *opts) { c.flags = f } }
 
+// Gracefully shut down on SIGINT or SIGTERM once successfully started. A
+// second signal terminates the process immediately. Always set by Run and
+// RunCLI, so only needed when using Start or MustStart directly.
+func WithSignalShutdown() OptFunc { return func(c *opts) { c.signalShutdown = true } }
+
 func WithLogger(l func() L.L) OptFunc { return func(c *opts) { c.l = l } }
 
 func WithCLIOptions(fs ...CLIOptFunc) OptFunc {
@@ -71,6 +86,11 @@ func WithCLIOptions(fs ...CLIOptFunc) OptFunc {
 // - context.Context
 // - *zap.SugaredLogger
 // - Configuration as was supplied to WithConfig.
+// - *HealthRegistry.
+// - AdminRouter.
+// - *prometheus.Registry (also as prometheus.Registerer).
+// - trace.TracerProvider and trace.Tracer (no-op if tracing is disabled).
+// - *LogLevels, unless the logger was set using WithLogger.
 //
 // For example:
 //
@@ -130,10 +150,14 @@ func WithReady(n string, f interface{}) OptFunc {
 
 // A component is a grouping of init/setup/start record that conceptually
 // belong to a specific component with a name.
+//
+// Tags group components together so they can be selected from the command
+// line using a "tag:" prefix, for example: --only tag:workers.
 type Component struct {
 	Name                      string
 	Init, Setup, Start, Ready interface{}
 	Disabled                  bool
+	Tags                      []string
 }
 
 func WithComponent(comps ...Component) OptFunc {
@@ -147,6 +171,8 @@ func WithComponent(comps ...Component) OptFunc {
 			if comp.Disabled {
 				WithDefaultDisable(comp.Name)(c)
 			}
+
+			WithTags(comp.Name, comp.Tags...)(c)
 		}
 	}
 }
@@ -156,6 +182,21 @@ func WithDefaultDisable(ns ...string) OptFunc {
 	return func(c *opts) { c.defaultDisables = append(c.defaultDisables, ns...) }
 }
 
+// Tag a component (or any other named With* function) with tags.
+func WithTags(n string, tags ...string) OptFunc {
+	return func(c *opts) {
+		if len(tags) == 0 {
+			return
+		}
+
+		if c.tags == nil {
+			c.tags = make(map[string][]string)
+		}
+
+		c.tags[n] = append(c.tags[n], tags...)
+	}
+}
+
 // Explicitly provide a new provider before initialization.
 func Provide(p interface{}) OptFunc {
 	return func(c *opts) { c.providers = append(c.providers, p) }
@@ -164,3 +205,33 @@ func Provide(p interface{}) OptFunc {
 func WithGRPC(enabled bool) OptFunc { return func(c *opts) { c.grpc = enabled } }
 
 func WithHTTP(enabled bool) OptFunc { return func(c *opts) { c.http = enabled } }
+
+// Serve the named server using lis instead of listening on the configured
+// addresses. Names are HTTPListener, GRPCListener, PprofListener and
+// AdminListener.
+// Multiple listeners can be given for the same name.
+//
+// Listeners are also inherited from the parent process using the systemd
+// socket activation protocol, which takes precedence over WithListener.
+func WithListener(name string, lis net.Listener) OptFunc {
+	return func(c *opts) {
+		if c.listeners == nil {
+			c.listeners = make(map[string][]net.Listener)
+		}
+
+		c.listeners[name] = append(c.listeners[name], lis)
+	}
+}
+
+// When running under systemd with WatchdogSec set, watchdog keep alive pings
+// are sent only while f succeeds.
+func WithWatchdogCheck(f func(context.Context) error) OptFunc {
+	return func(c *opts) { c.watchdogCheck = f }
+}
+
+// Call f for every recovered panic in HTTP handlers and GRPC methods.
+func WithPanicHook(f PanicHook) OptFunc { return func(c *opts) { c.panicHook = f } }
+
+// Rate limit both HTTP and GRPC requests by the key f returns, instead of
+// the configured built in key.
+func WithRateLimitKey(f RateLimitKeyFunc) OptFunc { return func(c *opts) { c.rateLimitKey = f } }
@@ -29,4 +29,11 @@ func (p *Providers) Get(dst interface{}) bool {
 	return false
 }
 
+// Address of the GRPC server. For unix sockets, this is the socket path.
+// If the server listens on multiple addresses, this is the first one.
 type GRPCAddr net.Addr
+
+// Address of the HTTP server. Same semantics as GRPCAddr.
+// This is a struct rather than an interface so it will not be confused
+// with GRPCAddr when fulfilling arguments.
+type HTTPAddr struct{ Addr net.Addr }
@@ -0,0 +1,314 @@
+package svc
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"path"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/time/rate"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+// Overrides the default limit for matching requests. Rules are matched in
+// order, first match wins.
+type rateLimitRuleCfg struct {
+	Prefix string  `json:"prefix"` // HTTP only: path prefix, see hasPathPrefix.
+	Method string  `json:"method"` // GRPC only: method pattern, as in grpcLogCfg.
+	Rate   float64 `json:"rate"`   // requests per second, no limit if not positive.
+	Burst  int     `json:"burst"`
+}
+
+// Token bucket rate limiting, with a bucket per key. See RateLimitKeyFunc.
+// GRPC calls transcoded from HTTP requests are only limited by the HTTP
+// limits.
+type rateLimitCfg struct {
+	Enabled bool    `envconfig:"ENABLED" json:"enabled"`
+	Rate    float64 `envconfig:"RATE" default:"10" json:"rate"` // requests per second per key, no limit if not positive.
+	Burst   int     `envconfig:"BURST" default:"20" json:"burst"`
+
+	// Built in key functions: ip, identity (see PeerIdentity, falls back to
+	// ip), route (route template or GRPC method) or global. Ignored if a key
+	// function was set using WithRateLimitKey.
+	Key string `envconfig:"KEY" default:"ip" json:"key"`
+
+	// Buckets unused for this long are dropped.
+	KeyTTL time.Duration `envconfig:"KEY_TTL" default:"10m" json:"key_ttl"`
+
+	// Only settable from the config file.
+	Rules []rateLimitRuleCfg `ignored:"true" json:"rules"`
+}
+
+// Describes a request being rate limited.
+type RateLimitRequest struct {
+	Kind  string        // "http" or "grpc".
+	Route string        // route template for HTTP, full method for GRPC.
+	IP    string        // remote address IP, proxies are not taken into account.
+	HTTP  *http.Request // nil for GRPC.
+}
+
+// Returns the key requests are limited by. Requests with the same key share
+// a bucket. Set using WithRateLimitKey.
+type RateLimitKeyFunc func(context.Context, RateLimitRequest) string
+
+func builtinRateLimitKey(name string) (RateLimitKeyFunc, error) {
+	switch name {
+	case "ip":
+		return func(_ context.Context, r RateLimitRequest) string { return r.IP }, nil
+	case "identity":
+		return func(ctx context.Context, r RateLimitRequest) string {
+			if id, ok := PeerIdentityFromContext(ctx); ok {
+				return "cn:" + id.CommonName
+			}
+
+			return r.IP
+		}, nil
+	case "route":
+		return func(_ context.Context, r RateLimitRequest) string { return r.Route }, nil
+	case "global":
+		return func(context.Context, RateLimitRequest) string { return "" }, nil
+	default:
+		return nil, fmt.Errorf("unknown key %q", name)
+	}
+}
+
+// Returned by check for requests that will never be allowed, as the burst
+// is zero.
+const rateLimitNever = time.Duration(math.MaxInt64)
+
+type rateLimitBucket struct {
+	lim  *rate.Limiter
+	last time.Time
+}
+
+type rateLimitRule struct {
+	name  string // metrics label.
+	match func(RateLimitRequest) bool
+	limit rate.Limit
+	burst int
+
+	mu      sync.Mutex
+	buckets map[string]*rateLimitBucket
+	swept   time.Time
+}
+
+type rateLimiter struct {
+	kind    string
+	key     RateLimitKeyFunc
+	ttl     time.Duration
+	rules   []*rateLimitRule // default rule is last.
+	limited *prometheus.CounterVec
+}
+
+func newRateLimiter(kind string, cfg rateLimitCfg, key RateLimitKeyFunc, m *metrics) (*rateLimiter, error) {
+	if key == nil {
+		var err error
+		if key, err = builtinRateLimitKey(cfg.Key); err != nil {
+			return nil, err
+		}
+	}
+
+	rl := rateLimiter{kind: kind, key: key, ttl: cfg.KeyTTL, limited: m.rateLimited}
+
+	for _, r := range cfg.Rules {
+		r := r
+
+		var rule rateLimitRule
+
+		switch kind {
+		case "http":
+			if r.Prefix == "" {
+				return nil, fmt.Errorf("rule without prefix")
+			}
+
+			rule.name = r.Prefix
+			rule.match = func(req RateLimitRequest) bool { return hasPathPrefix(req.HTTP.URL.Path, r.Prefix) }
+		case "grpc":
+			if r.Method == "" {
+				return nil, fmt.Errorf("rule without method")
+			}
+
+			if _, err := path.Match(r.Method, ""); err != nil {
+				return nil, fmt.Errorf("rule method pattern %q: %w", r.Method, err)
+			}
+
+			rule.name = r.Method
+			rule.match = func(req RateLimitRequest) bool { return matchMethod([]string{r.Method}, req.Route) }
+		}
+
+		rule.limit, rule.burst = rateLimit(r.Rate), r.Burst
+
+		rl.rules = append(rl.rules, &rule)
+	}
+
+	rl.rules = append(rl.rules, &rateLimitRule{
+		name:  "default",
+		match: func(RateLimitRequest) bool { return true },
+		limit: rateLimit(cfg.Rate),
+		burst: cfg.Burst,
+	})
+
+	return &rl, nil
+}
+
+func rateLimit(r float64) rate.Limit {
+	if r <= 0 {
+		return rate.Inf
+	}
+
+	return rate.Limit(r)
+}
+
+func (r *rateLimitRule) bucket(key string, now time.Time, ttl time.Duration) *rate.Limiter {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.buckets == nil {
+		r.buckets = make(map[string]*rateLimitBucket)
+	}
+
+	if ttl > 0 && now.Sub(r.swept) > ttl {
+		for k, b := range r.buckets {
+			if now.Sub(b.last) > ttl {
+				delete(r.buckets, k)
+			}
+		}
+
+		r.swept = now
+	}
+
+	b := r.buckets[key]
+	if b == nil {
+		b = &rateLimitBucket{lim: rate.NewLimiter(r.limit, r.burst)}
+		r.buckets[key] = b
+	}
+
+	b.last = now
+
+	return b.lim
+}
+
+// Returns zero if allowed, otherwise how long until a request would be
+// allowed.
+func (rl *rateLimiter) check(ctx context.Context, req RateLimitRequest) time.Duration {
+	var rule *rateLimitRule
+	for _, rule = range rl.rules {
+		if rule.match(req) {
+			break
+		}
+	}
+
+	if rule.limit == rate.Inf {
+		return 0
+	}
+
+	now := time.Now()
+
+	res := rule.bucket(rl.key(ctx, req), now, rl.ttl).ReserveN(now, 1)
+	if !res.OK() {
+		rl.limited.WithLabelValues(rl.kind, rule.name).Inc()
+		return rateLimitNever
+	}
+
+	if d := res.DelayFrom(now); d > 0 {
+		res.CancelAt(now)
+		rl.limited.WithLabelValues(rl.kind, rule.name).Inc()
+		return d
+	}
+
+	return 0
+}
+
+func remoteIP(addr string) string {
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		return host
+	}
+
+	return addr
+}
+
+// Retry-After is in whole seconds, rounded up.
+func retryAfterSeconds(d time.Duration) int64 {
+	return int64(math.Ceil(d.Seconds()))
+}
+
+// Mux middleware, so routes are known.
+func (rl *rateLimiter) httpMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req := RateLimitRequest{Kind: "http", IP: remoteIP(r.RemoteAddr), HTTP: r}
+
+		if cr := mux.CurrentRoute(r); cr != nil {
+			req.Route, _ = cr.GetPathTemplate()
+		}
+
+		if d := rl.check(r.Context(), req); d > 0 {
+			if d != rateLimitNever {
+				w.Header().Set("Retry-After", strconv.FormatInt(retryAfterSeconds(d), 10))
+			}
+
+			http.Error(w, http.StatusText(http.StatusTooManyRequests), http.StatusTooManyRequests)
+
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Transcoded calls are not limited, as they are limited as HTTP requests,
+// and the remote address of the in-process connection is not the client's.
+func (rl *rateLimiter) grpcCheck(ctx context.Context, fullMethod string) error {
+	if isInProcessGRPCCall(ctx) {
+		return nil
+	}
+
+	req := RateLimitRequest{Kind: "grpc", Route: fullMethod}
+
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		req.IP = remoteIP(p.Addr.String())
+	}
+
+	d := rl.check(ctx, req)
+	if d == 0 {
+		return nil
+	}
+
+	st := status.New(codes.ResourceExhausted, "rate limited")
+
+	if d != rateLimitNever {
+		if withInfo, err := st.WithDetails(&errdetails.RetryInfo{RetryDelay: durationpb.New(d)}); err == nil {
+			st = withInfo
+		}
+	}
+
+	return st.Err()
+}
+
+func (rl *rateLimiter) unaryInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	if err := rl.grpcCheck(ctx, info.FullMethod); err != nil {
+		return nil, err
+	}
+
+	return handler(ctx, req)
+}
+
+// Limits stream creation, not messages within streams.
+func (rl *rateLimiter) streamInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if err := rl.grpcCheck(ss.Context(), info.FullMethod); err != nil {
+		return err
+	}
+
+	return handler(srv, ss)
+}
@@ -0,0 +1,183 @@
+//go:build unit
+
+package svc
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+
+	"github.com/autokitteh/L"
+)
+
+func TestRateLimitHTTP(t *testing.T) {
+	m := newMetrics()
+
+	rl, err := newRateLimiter("http", rateLimitCfg{
+		Rate:  1,
+		Burst: 2,
+		Key:   "global",
+		Rules: []rateLimitRuleCfg{
+			{Prefix: "/free"},
+			{Prefix: "/strict", Rate: 1, Burst: 1},
+		},
+	}, nil, m)
+	require.NoError(t, err)
+
+	h := rl.httpMiddleware(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+
+	get := func(path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		return rec
+	}
+
+	assert.Equal(t, http.StatusOK, get("/").Code)
+	assert.Equal(t, http.StatusOK, get("/").Code)
+
+	rec := get("/")
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+	assert.Equal(t, "1", rec.Header().Get("Retry-After"))
+
+	for i := 0; i < 10; i++ {
+		assert.Equal(t, http.StatusOK, get("/free/meow").Code)
+	}
+
+	assert.Equal(t, http.StatusOK, get("/strict").Code)
+	assert.Equal(t, http.StatusTooManyRequests, get("/strict").Code)
+
+	// not under /free, so limited by the default rule.
+	assert.Equal(t, http.StatusTooManyRequests, get("/freedom").Code)
+
+	assert.Equal(t, 2.0, testutil.ToFloat64(m.rateLimited.WithLabelValues("http", "default")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.rateLimited.WithLabelValues("http", "/strict")))
+}
+
+func TestRateLimitGRPC(t *testing.T) {
+	m := newMetrics()
+
+	rl, err := newRateLimiter("grpc", rateLimitCfg{
+		Rate:  1,
+		Burst: 1,
+		Key:   "route",
+		Rules: []rateLimitRuleCfg{{Method: "grpc.health.v1.Health/*"}},
+	}, nil, m)
+	require.NoError(t, err)
+
+	call := func(method string) error {
+		_, err := rl.unaryInterceptor(
+			context.Background(),
+			nil,
+			&grpc.UnaryServerInfo{FullMethod: method},
+			func(context.Context, interface{}) (interface{}, error) { return nil, nil },
+		)
+		return err
+	}
+
+	assert.NoError(t, call("/svc.Test/A"))
+	assert.NoError(t, call("/svc.Test/B")) // different key.
+
+	err = call("/svc.Test/A")
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+
+	details := status.Convert(err).Details()
+	if assert.Len(t, details, 1) {
+		info, ok := details[0].(*errdetails.RetryInfo)
+		if assert.True(t, ok) {
+			assert.Positive(t, info.RetryDelay.AsDuration())
+		}
+	}
+
+	for i := 0; i < 10; i++ {
+		assert.NoError(t, call("/grpc.health.v1.Health/Check"))
+	}
+
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.rateLimited.WithLabelValues("grpc", "default")))
+}
+
+func TestRateLimitKey(t *testing.T) {
+	_, err := newRateLimiter("http", rateLimitCfg{Key: "meow"}, nil, newMetrics())
+	assert.Error(t, err)
+
+	_, err = newRateLimiter("http", rateLimitCfg{Key: "ip", Rules: []rateLimitRuleCfg{{Method: "x"}}}, nil, newMetrics())
+	assert.Error(t, err)
+
+	_, err = newRateLimiter("grpc", rateLimitCfg{Key: "ip", Rules: []rateLimitRuleCfg{{Method: "["}}}, nil, newMetrics())
+	assert.Error(t, err)
+
+	var keys []string
+
+	rl, err := newRateLimiter("http", rateLimitCfg{Rate: 1, Burst: 1}, func(_ context.Context, r RateLimitRequest) string {
+		keys = append(keys, r.IP)
+		return r.HTTP.Header.Get("X-Tenant")
+	}, newMetrics())
+	require.NoError(t, err)
+
+	h := rl.httpMiddleware(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+
+	for _, tenant := range []string{"a", "b"} {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("X-Tenant", tenant)
+
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusOK, rec.Code)
+	}
+
+	assert.Equal(t, []string{"192.0.2.1", "192.0.2.1"}, keys)
+}
+
+// Transcoded calls are limited only by the HTTP limiter, by the HTTP client's
+// address.
+func TestRateLimitTranscode(t *testing.T) {
+	m := newMetrics()
+
+	grpcRL, err := newRateLimiter("grpc", rateLimitCfg{Rate: 1, Burst: 1, Key: "ip"}, nil, m)
+	require.NoError(t, err)
+
+	httpRL, err := newRateLimiter("http", rateLimitCfg{Rate: 1, Burst: 2, Key: "ip"}, nil, m)
+	require.NoError(t, err)
+
+	srv := grpc.NewServer(grpc.UnaryInterceptor(grpcRL.unaryInterceptor))
+	healthpb.RegisterHealthServer(srv, health.NewServer())
+
+	sd := newShutdown(L.Nop, 0)
+	defer sd.run()
+
+	conn, err := inProcessGRPCConn(L.Nop, srv, nil, sd)
+	require.NoError(t, err)
+
+	r := mux.NewRouter()
+	r.Use(httpRL.httpMiddleware)
+	require.NoError(t, transcode(L.Nop, r, srv, conn, "/api"))
+
+	post := func(ip string) int {
+		req := httptest.NewRequest(http.MethodPost, "/api/grpc.health.v1.Health/Check", strings.NewReader("{}"))
+		req.RemoteAddr = ip + ":1234"
+
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	assert.Equal(t, http.StatusOK, post("192.0.2.1"))
+	assert.Equal(t, http.StatusOK, post("192.0.2.1"))
+	assert.Equal(t, http.StatusOK, post("192.0.2.2"))
+	assert.Equal(t, http.StatusTooManyRequests, post("192.0.2.1"))
+
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.rateLimited.WithLabelValues("http", "default")))
+	assert.Equal(t, 0.0, testutil.ToFloat64(m.rateLimited.WithLabelValues("grpc", "default")))
+}
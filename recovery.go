@@ -0,0 +1,113 @@
+package svc
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"runtime/debug"
+
+	"github.com/felixge/httpsnoop"
+	grpc_recovery "github.com/grpc-ecosystem/go-grpc-middleware/recovery"
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/autokitteh/L"
+)
+
+// Called with the recovered value and the stack of the panicking goroutine
+// after a panic in an HTTP handler or a GRPC method is recovered, for
+// example to forward it to an error reporting service. kind is either
+// "http" or "grpc".
+type PanicHook func(ctx context.Context, kind string, p interface{}, stack []byte)
+
+// Panics are logged through the logger of the server they happened in,
+// "http" or "grpc", with the request ID when known.
+type recovery struct {
+	l       L.L
+	kind    string
+	counter prometheus.Counter
+	hook    PanicHook // nil if not set.
+}
+
+func newRecovery(l L.L, kind string, m *metrics, hook PanicHook) *recovery {
+	return &recovery{l: l, kind: kind, counter: m.panics.WithLabelValues(kind), hook: hook}
+}
+
+func (r *recovery) recovered(ctx context.Context, p interface{}, stack []byte, kvs ...interface{}) {
+	r.counter.Inc()
+
+	RequestLogger(ctx, r.l).Error("panic recovered", append(kvs, "kind", r.kind, "panic", fmt.Sprintf("%v", p), "stack", string(stack))...)
+
+	if r.hook != nil {
+		r.hook(ctx, r.kind, p, stack)
+	}
+}
+
+// Responds with 500 unless a response was already started. Used both as a
+// mux middleware and around the whole HTTP server chain.
+func (r *recovery) httpMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		wroteHeader := false
+
+		w = httpsnoop.Wrap(w, httpsnoop.Hooks{
+			WriteHeader: func(f httpsnoop.WriteHeaderFunc) httpsnoop.WriteHeaderFunc {
+				return func(code int) {
+					wroteHeader = true
+					f(code)
+				}
+			},
+			Write: func(f httpsnoop.WriteFunc) httpsnoop.WriteFunc {
+				return func(bs []byte) (int, error) {
+					wroteHeader = true
+					return f(bs)
+				}
+			},
+		})
+
+		defer func() {
+			p := recover()
+			if p == nil {
+				return
+			}
+
+			if p == http.ErrAbortHandler {
+				// used to abort a response deliberately.
+				panic(p)
+			}
+
+			r.recovered(req.Context(), p, debug.Stack(), "method", req.Method, "path", req.URL.Path)
+
+			if !wroteHeader {
+				http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			}
+		}()
+
+		next.ServeHTTP(w, req)
+	})
+}
+
+func (r *recovery) grpcOptions() []grpc_recovery.Option {
+	return []grpc_recovery.Option{
+		grpc_recovery.WithRecoveryHandlerContext(func(ctx context.Context, p interface{}) error {
+			// still in the deferred call, so the stack includes the panic.
+			r.recovered(ctx, p, debug.Stack(), "method", grpcMethod(ctx))
+
+			return status.Error(codes.Internal, "internal error")
+		}),
+	}
+}
+
+func (r *recovery) unaryInterceptor() grpc.UnaryServerInterceptor {
+	return grpc_recovery.UnaryServerInterceptor(r.grpcOptions()...)
+}
+
+func (r *recovery) streamInterceptor() grpc.StreamServerInterceptor {
+	return grpc_recovery.StreamServerInterceptor(r.grpcOptions()...)
+}
+
+func grpcMethod(ctx context.Context) string {
+	m, _ := grpc.Method(ctx)
+	return m
+}
@@ -0,0 +1,118 @@
+//go:build unit
+
+package svc
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/autokitteh/L"
+	"github.com/autokitteh/L/Z"
+)
+
+func TestRecoveryHTTP(t *testing.T) {
+	m := newMetrics()
+
+	var hooked interface{}
+
+	r := newRecovery(L.Nop, "http", m, func(_ context.Context, kind string, p interface{}, stack []byte) {
+		assert.Equal(t, "http", kind)
+		assert.NotEmpty(t, stack)
+		hooked = p
+	})
+
+	h := r.httpMiddleware(http.HandlerFunc(func(http.ResponseWriter, *http.Request) { panic("meow") }))
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+	assert.Equal(t, "meow", hooked)
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.panics.WithLabelValues("http")))
+
+	// response already started.
+	h = r.httpMiddleware(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+		panic("woof")
+	}))
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	assert.Equal(t, http.StatusAccepted, rec.Code)
+
+	h = r.httpMiddleware(http.HandlerFunc(func(http.ResponseWriter, *http.Request) { panic(http.ErrAbortHandler) }))
+
+	assert.PanicsWithValue(t, http.ErrAbortHandler, func() {
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	})
+}
+
+func TestRecoveryGRPC(t *testing.T) {
+	m := newMetrics()
+	r := newRecovery(L.Nop, "grpc", m, nil)
+
+	_, err := r.unaryInterceptor()(context.Background(), nil, &grpc.UnaryServerInfo{}, func(context.Context, interface{}) (interface{}, error) {
+		panic("meow")
+	})
+
+	assert.Equal(t, codes.Internal, status.Code(err))
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.panics.WithLabelValues("grpc")))
+}
+
+func TestRecoveryLog(t *testing.T) {
+	core, logs := observer.New(zapcore.DebugLevel)
+
+	r := newRecovery(&Z.ZL{Z: zap.New(core).Sugar().Named("http")}, "http", newMetrics(), nil)
+
+	h := withRequestID(r.httpMiddleware(http.HandlerFunc(func(http.ResponseWriter, *http.Request) { panic("meow") })))
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(RequestIDHeader, "woof")
+
+	h.ServeHTTP(httptest.NewRecorder(), req)
+
+	if entries := logs.FilterMessage("panic recovered").All(); assert.Len(t, entries, 1) {
+		assert.Equal(t, "http", entries[0].LoggerName)
+		assert.Equal(t, "woof", entries[0].ContextMap()[requestIDLogField])
+		assert.Equal(t, "meow", entries[0].ContextMap()["panic"])
+	}
+}
+
+// Panics in svc's own wrappers are recovered as well.
+func TestRecoveryHTTPServer(t *testing.T) {
+	m := newMetrics()
+
+	withCORS := func(http.Handler) http.Handler {
+		return http.HandlerFunc(func(http.ResponseWriter, *http.Request) { panic("meow") })
+	}
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	sd := newShutdown(L.Nop, 0)
+	defer sd.run()
+
+	addr := startHTTP(&Z.ZL{Z: zap.NewNop().Sugar()}, mux.NewRouter(), nil, nil, httpCfg{}, withCORS, newRecovery(L.Nop, "http", m, nil), nil, []net.Listener{lis}, sd, make(chan error, 1))
+
+	resp, err := http.Get("http://" + addr.Addr.String() + "/")
+	require.NoError(t, err)
+	resp.Body.Close()
+
+	assert.Equal(t, http.StatusInternalServerError, resp.StatusCode)
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.panics.WithLabelValues("http")))
+}
@@ -0,0 +1,117 @@
+package svc
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+
+	grpc_middleware "github.com/grpc-ecosystem/go-grpc-middleware"
+	"github.com/grpc-ecosystem/go-grpc-middleware/logging/zap/ctxzap"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+
+	"github.com/autokitteh/L"
+)
+
+const (
+	RequestIDHeader   = "X-Request-ID"
+	RequestIDMetadata = "x-request-id"
+
+	requestIDLogField = "request_id"
+	maxRequestIDLen   = 128
+)
+
+type requestIDCtxKey struct{}
+
+// Returns the ID of the HTTP request or GRPC call served by svc. The ID is
+// taken from the request, if valid, or generated otherwise.
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDCtxKey{}).(string)
+	return id
+}
+
+// Returns l with the request ID of ctx, if any, so component logs can be
+// correlated with the access and GRPC logs.
+func RequestLogger(ctx context.Context, l L.L) L.L {
+	if id := RequestIDFromContext(ctx); id != "" {
+		return l.With(requestIDLogField, id)
+	}
+
+	return l
+}
+
+func newRequestID() string {
+	var bs [16]byte
+	_, _ = rand.Read(bs[:])
+
+	return hex.EncodeToString(bs[:])
+}
+
+// Incoming IDs are logged and echoed, so only reasonably short printable
+// IDs are accepted.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLen {
+		return false
+	}
+
+	for _, c := range id {
+		if c <= ' ' || c > '~' {
+			return false
+		}
+	}
+
+	return true
+}
+
+func requestID(incoming string) string {
+	if validRequestID(incoming) {
+		return incoming
+	}
+
+	return newRequestID()
+}
+
+// The ID is also set in the request header, so it is forwarded to
+// transcoded GRPC calls.
+func withRequestID(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := requestID(r.Header.Get(RequestIDHeader))
+
+		r.Header.Set(RequestIDHeader, id)
+		w.Header().Set(RequestIDHeader, id)
+
+		h.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), requestIDCtxKey{}, id)))
+	})
+}
+
+// Must be chained after grpc_zap's interceptor, so the ID is added to its
+// logger.
+func grpcRequestID(ctx context.Context) context.Context {
+	var incoming string
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if vs := md.Get(RequestIDMetadata); len(vs) != 0 {
+			incoming = vs[0]
+		}
+	}
+
+	id := requestID(incoming)
+
+	_ = grpc.SetHeader(ctx, metadata.Pairs(RequestIDMetadata, id))
+
+	ctxzap.AddFields(ctx, zap.String(requestIDLogField, id))
+
+	return context.WithValue(ctx, requestIDCtxKey{}, id)
+}
+
+func requestIDUnaryInterceptor(ctx context.Context, req interface{}, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	return handler(grpcRequestID(ctx), req)
+}
+
+func requestIDStreamInterceptor(srv interface{}, ss grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	wrapped := grpc_middleware.WrapServerStream(ss)
+	wrapped.WrappedContext = grpcRequestID(ss.Context())
+
+	return handler(srv, wrapped)
+}
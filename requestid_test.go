@@ -0,0 +1,57 @@
+//go:build unit
+
+package svc
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+func TestRequestIDHTTP(t *testing.T) {
+	var got string
+
+	h := withRequestID(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		got = RequestIDFromContext(r.Context())
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(RequestIDHeader, "meow-1")
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	assert.Equal(t, "meow-1", got)
+	assert.Equal(t, "meow-1", rec.Header().Get(RequestIDHeader))
+
+	for _, id := range []string{"", "with space", strings.Repeat("x", maxRequestIDLen+1)} {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set(RequestIDHeader, id)
+
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+
+		assert.Len(t, got, 32, id)
+		assert.Equal(t, got, rec.Header().Get(RequestIDHeader), id)
+	}
+}
+
+func TestRequestIDGRPC(t *testing.T) {
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(RequestIDMetadata, "meow-1"))
+
+	_, _ = requestIDUnaryInterceptor(ctx, nil, &grpc.UnaryServerInfo{}, func(ctx context.Context, _ interface{}) (interface{}, error) {
+		assert.Equal(t, "meow-1", RequestIDFromContext(ctx))
+		return nil, nil
+	})
+
+	_, _ = requestIDUnaryInterceptor(context.Background(), nil, &grpc.UnaryServerInfo{}, func(ctx context.Context, _ interface{}) (interface{}, error) {
+		assert.Len(t, RequestIDFromContext(ctx), 32)
+		return nil, nil
+	})
+}
@@ -0,0 +1,87 @@
+package svc
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/autokitteh/L"
+)
+
+type shutdownHook struct {
+	n string
+	f func(context.Context) error
+}
+
+// Runs registered hooks, in reverse order of registration, when the
+// process is signaled to terminate, if watched (see WithSignalShutdown).
+// A second signal terminates the process immediately.
+type shutdown struct {
+	l       L.L
+	timeout time.Duration
+
+	mu    sync.Mutex
+	hooks []shutdownHook
+}
+
+func newShutdown(l L.L, timeout time.Duration) *shutdown {
+	return &shutdown{l: l, timeout: timeout}
+}
+
+func (s *shutdown) add(n string, f func(context.Context) error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.hooks = append(s.hooks, shutdownHook{n: n, f: f})
+}
+
+// Wait for a termination signal in the background. Once received, all hooks
+// are called and then nil is sent to errCh.
+func (s *shutdown) watch(errCh chan<- error) {
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, os.Interrupt, syscall.SIGTERM)
+
+	go func() {
+		sig := <-ch
+		signal.Stop(ch)
+
+		s.l.Info("shutting down", "signal", sig)
+
+		s.run()
+
+		select {
+		case errCh <- nil:
+		default:
+		}
+	}()
+}
+
+func (s *shutdown) run() {
+	ctx := context.Background()
+
+	if s.timeout > 0 {
+		var cancel func()
+		ctx, cancel = context.WithTimeout(ctx, s.timeout)
+		defer cancel()
+	}
+
+	s.mu.Lock()
+	hooks := s.hooks
+	s.hooks = nil
+	s.mu.Unlock()
+
+	for i := len(hooks) - 1; i >= 0; i-- {
+		h := hooks[i]
+
+		s.l.Debug("shutting down", "what", h.n)
+
+		if err := h.f(ctx); err != nil {
+			s.l.Warn("shutdown error", "what", h.n, "err", err)
+		}
+	}
+
+	s.l.Info("shutdown complete")
+}
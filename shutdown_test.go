@@ -0,0 +1,86 @@
+//go:build unit
+
+package svc
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Runs in a child process started by TestRunSignalShutdown.
+func TestRunSignalShutdownChild(t *testing.T) {
+	if os.Getenv("SVC_TEST_CHILD") == "" {
+		t.Skip("not a child process")
+	}
+
+	Run(WithName("svctest"), WithFlags(&Flags{}), WithHTTP(true))
+}
+
+func TestRunSignalShutdown(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "http.sock")
+
+	cmd := exec.Command(os.Args[0], "-test.run", "^TestRunSignalShutdownChild$")
+	cmd.Env = append(
+		os.Environ(),
+		"SVC_TEST_CHILD=1",
+		"SVCTEST_HTTP_ADDRS="+unixAddrPrefix+path,
+	)
+	cmd.Stdout = os.Stdout
+
+	stderr, err := cmd.StderrPipe()
+	require.NoError(t, err)
+
+	require.NoError(t, cmd.Start())
+
+	defer func() { _ = cmd.Process.Kill() }()
+
+	// logged only once signals are handled.
+	ready, done := make(chan struct{}), make(chan struct{})
+
+	go func() {
+		defer close(done)
+
+		scanner := bufio.NewScanner(stderr)
+		for scanner.Scan() {
+			line := scanner.Text()
+			fmt.Fprintln(os.Stderr, line)
+
+			if strings.Contains(line, "ready!") {
+				close(ready)
+				break
+			}
+		}
+
+		_, _ = io.Copy(os.Stderr, stderr)
+	}()
+
+	select {
+	case <-ready:
+	case <-time.After(10 * time.Second):
+		require.FailNow(t, "child not ready")
+	}
+
+	_, err = os.Stat(path)
+	require.NoError(t, err)
+
+	require.NoError(t, cmd.Process.Signal(syscall.SIGTERM))
+
+	<-done
+
+	// Run returns once the shutdown hooks ran.
+	assert.NoError(t, cmd.Wait())
+
+	_, err = os.Stat(path)
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
@@ -0,0 +1,34 @@
+package svc
+
+import (
+	"net/http"
+	"strings"
+
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+	"google.golang.org/grpc"
+)
+
+// Route GRPC requests to grpcSrv, and everything else to h. If h2c is
+// true, unencrypted HTTP/2 is accepted, which is required for GRPC
+// without TLS.
+func withGRPC(grpcSrv *grpc.Server, h http.Handler, h2cEnabled bool) http.Handler {
+	var mixed http.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if isGRPCRequest(r) {
+			grpcSrv.ServeHTTP(w, r)
+			return
+		}
+
+		h.ServeHTTP(w, r)
+	})
+
+	if h2cEnabled {
+		mixed = h2c.NewHandler(mixed, &http2.Server{})
+	}
+
+	return mixed
+}
+
+func isGRPCRequest(r *http.Request) bool {
+	return r.ProtoMajor == 2 && strings.HasPrefix(r.Header.Get("Content-Type"), "application/grpc")
+}
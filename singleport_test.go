@@ -0,0 +1,104 @@
+//go:build unit
+
+package svc
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+
+	"github.com/autokitteh/L"
+	"github.com/autokitteh/L/Z"
+)
+
+func TestWithGRPC(t *testing.T) {
+	grpcSrv := grpc.NewServer()
+	healthpb.RegisterHealthServer(grpcSrv, health.NewServer())
+
+	srv := &http.Server{
+		Handler: withGRPC(grpcSrv, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte("meow"))
+		}), true),
+	}
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	go func() { _ = srv.Serve(lis) }()
+
+	defer srv.Close()
+
+	resp, err := http.Get("http://" + lis.Addr().String())
+	require.NoError(t, err)
+
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+
+	assert.Equal(t, "meow", string(body))
+
+	conn, err := grpc.Dial(lis.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+
+	defer conn.Close()
+
+	resp2, err := healthpb.NewHealthClient(conn).Check(context.Background(), &healthpb.HealthCheckRequest{})
+	if assert.NoError(t, err) {
+		assert.Equal(t, healthpb.HealthCheckResponse_SERVING, resp2.Status)
+	}
+}
+
+// With TLS, the HTTP server waits for open HTTP/2 streams on shutdown.
+func TestWithGRPCShutdownOpenStream(t *testing.T) {
+	ca := newTestCert(t, "ca", nil)
+
+	tlsConfig, _, err := newTLSConfig(L.Nop, writeTestCerts(t, t.TempDir(), newTestCert(t, "server", ca), ca), []string{"h2", "http/1.1"})
+	require.NoError(t, err)
+
+	grpcSrv := grpc.NewServer()
+	healthpb.RegisterHealthServer(grpcSrv, health.NewServer())
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	sd := newShutdown(L.Nop, 5*time.Second)
+
+	addr := startHTTP(&Z.ZL{Z: zap.NewNop().Sugar()}, mux.NewRouter(), nil, grpcSrv, httpCfg{}, nil, nil, tlsConfig, []net.Listener{lis}, sd, make(chan error, 1))
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+
+	conn, err := grpc.Dial(addr.Addr.String(), grpc.WithTransportCredentials(credentials.NewTLS(&tls.Config{RootCAs: roots})))
+	require.NoError(t, err)
+
+	defer conn.Close()
+
+	stream, err := healthpb.NewHealthClient(conn).Watch(context.Background(), &healthpb.HealthCheckRequest{})
+	require.NoError(t, err)
+
+	// stream is open once the initial status is received.
+	_, err = stream.Recv()
+	require.NoError(t, err)
+
+	t0 := time.Now()
+	sd.run()
+
+	assert.Less(t, time.Since(t0), time.Second)
+
+	_, err = stream.Recv()
+	assert.Error(t, err)
+}
@@ -2,24 +2,23 @@ package svc
 
 import (
 	"context"
+	"crypto/tls"
 	"errors"
 	"flag"
 	"fmt"
 	"net"
 	"net/http"
-	_ "net/http/pprof" // pprof
 	"os"
 	"path/filepath"
 	"strings"
 
 	"github.com/gorilla/handlers"
 	"github.com/gorilla/mux"
-	grpc_middleware "github.com/grpc-ecosystem/go-grpc-middleware"
 	grpc_zap "github.com/grpc-ecosystem/go-grpc-middleware/logging/zap"
-	"github.com/rs/cors"
-	"go.uber.org/zap/zapcore"
 	"google.golang.org/grpc"
-	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
 
 	"github.com/autokitteh/L"
 	"github.com/autokitteh/L/Z"
@@ -28,10 +27,16 @@ import (
 
 var DefaultServiceName = filepath.Base(os.Args[0])
 
-type svc struct{ opts opts }
+type svc struct {
+	opts      opts
+	listeners map[string][]net.Listener
+}
 
+// Start the service and wait until it is done. Since Run owns the process,
+// signal shutdown is always enabled (see WithSignalShutdown). RunCLI uses
+// Run as well.
 func Run(opts ...OptFunc) {
-	if err := <-MustStart(opts...); err != nil {
+	if err := <-MustStart(append(opts, WithSignalShutdown())...); err != nil {
 		panic(err)
 	}
 }
@@ -70,16 +75,17 @@ func (i *stringsListFlag) Set(value string) error {
 func parseFlags() *Flags {
 	var enables, disables, onlys, excepts stringsListFlag
 
-	flag.Var(&enables, "enable", "modules to enable")
-	flag.Var(&disables, "disable", "modules to disable")
-	flag.Var(&onlys, "only", "enable only these modules")
-	flag.Var(&excepts, "except", "disable only these modules")
+	flag.Var(&enables, "enable", "modules to enable (names, globs or tag:<tag>)")
+	flag.Var(&disables, "disable", "modules to disable (names, globs or tag:<tag>)")
+	flag.Var(&onlys, "only", "enable only these modules (names, globs or tag:<tag>)")
+	flag.Var(&excepts, "except", "disable only these modules (names, globs or tag:<tag>)")
 
 	cfgPathFlag := flag.String("config", "", "use config file")
 	setupFlag := flag.Bool("setup", false, "run setup pahse")
 	helpConfigFlag := flag.Bool("help-config", false, "describe accepted environment variables and exit")
 	printConfigFlag := flag.Bool("print-config", false, "print config")
 	exitBeforeStartFlag := flag.Bool("exit-before-start", false, "exit before start")
+	listComponentsFlag := flag.Bool("list-components", false, "list components and whether they are enabled and exit")
 
 	flag.Parse()
 
@@ -93,6 +99,7 @@ func parseFlags() *Flags {
 		HelpConfig:      *helpConfigFlag,
 		PrintConfig:     *printConfigFlag,
 		ExitBeforeStart: *exitBeforeStartFlag,
+		ListComponents:  *listComponentsFlag,
 	}
 }
 
@@ -105,6 +112,13 @@ func parseFlags() *Flags {
 // 3. Call user Init functions.
 // 4. If -setup is specified, call user Setup functions.
 // 5. If -exit-before-start is not specified, call user Start functions.
+//
+// If WithSignalShutdown is specified, which Run and RunCLI always do, once
+// started, SIGINT or SIGTERM will gracefully shut down the servers and nil
+// will be sent on the returned channel. Shutdown removes unix sockets,
+// reports readiness as failing, notifies systemd and flushes traces. Without
+// it, none of these happen, as the shutdown is left to the caller's process
+// exit.
 func Start(opts ...OptFunc) (<-chan error, error) {
 	var svc svc
 
@@ -117,73 +131,9 @@ func Start(opts ...OptFunc) (<-chan error, error) {
 		flags = parseFlags()
 	}
 
-	c := 0
-	if len(flags.Onlys) != 0 {
-		c++
-	}
-	if len(flags.Excepts) != 0 {
-		c++
-	}
-
-	if c > 1 {
-		return nil, errors.New("--only and --excepts are mutually exclusive")
-	}
-
-	moduleFilter := func(n string) bool {
-		for _, e := range flags.Enables {
-			if n == e {
-				return true
-			}
-		}
-
-		for _, e := range flags.Disables {
-			if n == e {
-				return false
-			}
-		}
-
-		for _, e := range svc.opts.defaultDisables {
-			if n == e {
-				return false
-			}
-		}
-
-		if onlys := flags.Onlys; len(onlys) != 0 {
-			for _, e := range onlys {
-				if n == e {
-					return true
-				}
-			}
-
-			return false
-		}
-
-		if excepts := flags.Excepts; len(excepts) != 0 {
-			for _, e := range excepts {
-				if n == e {
-					return false
-				}
-			}
-
-			return true
-		}
-
-		return true
-	}
-
-	modulesFilter := func(cbs []callback) (enabled, disabled []callback) {
-		enabled = make([]callback, 0, len(cbs))
-		disabled = make([]callback, 0, len(cbs))
-
-		for _, cb := range cbs {
-			if moduleFilter(cb.n) {
-				enabled = append(enabled, cb)
-			} else {
-				disabled = append(disabled, cb)
-			}
-		}
-
-		return
+	filter, err := newComponentsFilter(flags, &svc.opts)
+	if err != nil {
+		return nil, err
 	}
 
 	name := DefaultServiceName
@@ -201,6 +151,13 @@ func Start(opts ...OptFunc) (<-chan error, error) {
 		return errCh, nil
 	}
 
+	if flags.ListComponents {
+		printComponents(os.Stdout, resolveComponents(&svc.opts, filter))
+
+		errCh <- nil
+		return errCh, nil
+	}
+
 	providers := &Providers{Vs: svc.opts.providers}
 	providers.Add(providers)
 
@@ -211,15 +168,20 @@ func Start(opts ...OptFunc) (<-chan error, error) {
 
 	providers.Add(cfg)
 
-	var l L.L
+	var (
+		l         L.L
+		logLevels *LogLevels
+	)
 
 	if f := svc.opts.l; f != nil {
 		l = L.N(f())
 	} else {
-		l, err = Z.NewL(cfg.Log, nil, nil)
+		l, logLevels, err = newLeveledLogger(cfg.Log)
 		if err != nil {
 			return nil, fmt.Errorf("init log error: %w", err)
 		}
+
+		providers.Add(logLevels)
 	}
 
 	l.Debug("log init")
@@ -240,27 +202,122 @@ func Start(opts ...OptFunc) (<-chan error, error) {
 		l.Info("configs", "svc_cfg", cfg, "user_cfgs", svc.opts.cfgs)
 	}
 
-	if cfg.PprofPort != 0 {
-		l.Debug("starting pprof server", "port", cfg.PprofPort)
+	inherited, err := inheritedListeners()
+	if err != nil {
+		return nil, fmt.Errorf("inherited listeners error: %w", err)
+	}
+
+	svc.listeners = svc.opts.listeners
 
-		go func() {
-			err := http.ListenAndServe(fmt.Sprintf("localhost:%d", cfg.PprofPort), nil)
-			l.Errorf("pprof exited", "err", err)
-			errCh <- fmt.Errorf("pprof exited: %w", err)
-		}()
+	for n, liss := range dropUnknownListeners(l, inherited) {
+		l.Debug("inherited listeners", "name", n, "n", len(liss))
+
+		if svc.listeners == nil {
+			svc.listeners = make(map[string][]net.Listener)
+		}
+
+		svc.listeners[n] = liss
+	}
+
+	notify, err := newNotifierFromEnv(l.Named("notify"))
+	if err != nil {
+		return nil, fmt.Errorf("notify init error: %w", err)
+	}
+
+	sd := newShutdown(l.Named("shutdown"), cfg.ShutdownTimeout)
+
+	if cfg.PprofPort != 0 || len(cfg.PprofAddrs) != 0 || len(svc.listeners[PprofListener]) != 0 {
+		liss, err := svc.listen(PprofListener, listenAddrs(cfg.PprofHost, cfg.PprofPort, cfg.PprofAddrs), unixSocketCfg{})
+		if err != nil {
+			return nil, fmt.Errorf("pprof listen error: %w", err)
+		}
+
+		pprofRouter := mux.NewRouter()
+		addPprofRoutes(pprofRouter)
+
+		startAdmin(l.Named("pprof"), "pprof", pprofRouter, liss, sd, errCh)
 	}
 
 	ctx := context.Background()
 
 	providers.Add(ctx)
 
-	var grpcOpts GRPCOptions
+	healthReg := &HealthRegistry{}
+	providers.Add(healthReg)
+
+	m := newMetrics()
+	providers.Add(m.reg)
+
+	grpcLog, err := newGRPCLogger(cfg.GRPC.Log)
+	if err != nil {
+		return nil, fmt.Errorf("grpc log cfg error: %w", err)
+	}
+
+	withCORS, err := newCORS(cfg.HTTP)
+	if err != nil {
+		return nil, fmt.Errorf("cors cfg error: %w", err)
+	}
+
+	var httpRateLimiter, grpcRateLimiter *rateLimiter
+
+	if cfg.HTTP.RateLimit.Enabled {
+		if httpRateLimiter, err = newRateLimiter("http", cfg.HTTP.RateLimit, svc.opts.rateLimitKey, m); err != nil {
+			return nil, fmt.Errorf("http rate limit cfg error: %w", err)
+		}
+	}
+
+	if cfg.GRPC.RateLimit.Enabled {
+		if grpcRateLimiter, err = newRateLimiter("grpc", cfg.GRPC.RateLimit, svc.opts.rateLimitKey, m); err != nil {
+			return nil, fmt.Errorf("grpc rate limit cfg error: %w", err)
+		}
+	}
+
+	tr, err := newTracing(name, cfg.Tracing)
+	if err != nil {
+		return nil, fmt.Errorf("tracing init error: %w", err)
+	}
+
+	providers.Add(tr.tp, tr.tracer(name))
+
+	if tr.shutdown != nil {
+		// added before any server, so it is called last and flushes all spans.
+		sd.add("tracing", tr.shutdown)
+	}
+
+	adminRouter := newAdminRouter(adminDeps{
+		cfg:      cfg,
+		userCfgs: svc.opts.cfgs,
+		health:   healthReg,
+		levels:   logLevels,
+	})
+
+	providers.Add(adminRouter)
+
+	if cfg.Metrics.Enabled {
+		switch cfg.Metrics.Server {
+		case "admin":
+			adminRouter.Handle(cfg.Metrics.Path, m.handler())
+		case "http":
+			// added once the http router is created.
+		default:
+			return nil, fmt.Errorf("invalid metrics server %q", cfg.Metrics.Server)
+		}
+	}
+
+	var (
+		grpcOpts        GRPCOptions
+		httpMiddlewares HTTPMiddlewares
+	)
+
+	notify.status("initializing")
 
-	if inits, _ := modulesFilter(svc.opts.inits); len(inits) == 0 {
+	endPhase := m.phase("init")
+
+	providers.Add(&grpcOpts, &httpMiddlewares)
+
+	if inits, _ := filter.filter(svc.opts.inits); len(inits) == 0 {
 		l.Debug("nothing to initialize")
 	} else {
-		providers.Add(&grpcOpts)
-
 		l.Debug("initializing", "components", callbacksNames(inits))
 
 		for _, i := range inits {
@@ -272,8 +329,14 @@ func Start(opts ...OptFunc) (<-chan error, error) {
 		}
 	}
 
+	endPhase()
+
 	if flags.Setup {
-		if setups, _ := modulesFilter(svc.opts.setups); len(setups) == 0 {
+		notify.status("setting up")
+
+		endPhase := m.phase("setup")
+
+		if setups, _ := filter.filter(svc.opts.setups); len(setups) == 0 {
 			l.Info("nothing to setup")
 		} else {
 			l.Info("setting up", "components", callbacksNames(setups))
@@ -286,6 +349,8 @@ func Start(opts ...OptFunc) (<-chan error, error) {
 				}
 			}
 		}
+
+		endPhase()
 	}
 
 	if flags.ExitBeforeStart {
@@ -295,41 +360,124 @@ func Start(opts ...OptFunc) (<-chan error, error) {
 		return errCh, nil
 	}
 
+	grpcRecovery := newRecovery(l.Named("grpc"), "grpc", m, svc.opts.panicHook)
+
+	// recovery is both outermost, for panics in the interceptors, and
+	// innermost, so the interceptors observe recovered calls as failed.
+	unaryInterceptors := []grpc.UnaryServerInterceptor{grpcRecovery.unaryInterceptor()}
+	streamInterceptors := []grpc.StreamServerInterceptor{grpcRecovery.streamInterceptor()}
+
+	if cfg.Tracing.Enabled {
+		unaryInterceptors = append(unaryInterceptors, tr.unaryInterceptor())
+		streamInterceptors = append(streamInterceptors, tr.streamInterceptor())
+	}
+
+	if cfg.Metrics.Enabled {
+		unaryInterceptors = append(unaryInterceptors, m.unaryInterceptor)
+		streamInterceptors = append(streamInterceptors, m.streamInterceptor)
+	}
+
+	unaryInterceptors = append(
+		unaryInterceptors,
+		grpc_zap.UnaryServerInterceptor(Z.FromL(l.Named("grpc")).Desugar(), grpcLog.options()...),
+	)
+
+	streamInterceptors = append(
+		streamInterceptors,
+		grpc_zap.StreamServerInterceptor(Z.FromL(l.Named("grpc-stream")).Desugar(), grpcLog.options()...),
+	)
+
+	unaryInterceptors = append(unaryInterceptors, requestIDUnaryInterceptor)
+	streamInterceptors = append(streamInterceptors, requestIDStreamInterceptor)
+
+	if grpcRateLimiter != nil {
+		unaryInterceptors = append(unaryInterceptors, grpcRateLimiter.unaryInterceptor)
+		streamInterceptors = append(streamInterceptors, grpcRateLimiter.streamInterceptor)
+	}
+
+	if cfg.GRPC.Log.Payloads {
+		unaryInterceptors = append(unaryInterceptors, grpcLog.payloadUnaryInterceptor)
+		streamInterceptors = append(streamInterceptors, grpcLog.payloadStreamInterceptor)
+	}
+
+	unaryInterceptors = append(unaryInterceptors, grpcRecovery.unaryInterceptor())
+	streamInterceptors = append(streamInterceptors, grpcRecovery.streamInterceptor())
+
+	// see GRPCOptions for the complete chain.
 	grpcOpts.Add(
-		grpc.UnaryInterceptor(
-			grpc_middleware.ChainUnaryServer(
-				grpc_zap.UnaryServerInterceptor(
-					Z.FromL(l.Named("grpc")).Desugar(),
-					grpc_zap.WithLevels(func(codes.Code) zapcore.Level { return zapcore.DebugLevel }),
-				),
-			),
-		),
-		grpc.StreamInterceptor(
-			grpc_middleware.ChainStreamServer(
-				grpc_zap.StreamServerInterceptor(
-					Z.FromL(l.Named("grpc-stream")).Desugar(),
-					grpc_zap.WithLevels(func(codes.Code) zapcore.Level { return zapcore.DebugLevel }),
-				),
-			),
-		),
+		grpc.ChainUnaryInterceptor(append(unaryInterceptors, grpcOpts.unaryInterceptor)...),
+		grpc.ChainStreamInterceptor(append(streamInterceptors, grpcOpts.streamInterceptor)...),
 	)
 
-	if s := cfg.GRPC.MaxSendMsgSize; s > 0 {
-		grpcOpts.Add(grpc.MaxSendMsgSize(s))
+	if unarys, streams := grpcOpts.interceptorsNames(); len(unarys)+len(streams) != 0 {
+		l.Debug("grpc component interceptors", "unary", unarys, "stream", streams)
 	}
 
-	if s := cfg.GRPC.MaxRecvMsgSize; s > 0 {
-		grpcOpts.Add(grpc.MaxRecvMsgSize(s))
+	grpcOpts.Add(cfg.GRPC.serverOptions()...)
+
+	grpcTLSConfig, err := startTLS(l.Named("grpc"), cfg.GRPC.TLS, []string{"h2"}, sd)
+	if err != nil {
+		return nil, fmt.Errorf("grpc tls error: %w", err)
+	}
+
+	if grpcTLSConfig != nil {
+		grpcOpts.Add(grpc.Creds(credentials.NewTLS(grpcTLSConfig)))
 	}
 
 	grpcSrv := grpc.NewServer(grpcOpts.opts...)
 
 	providers.Add(grpcSrv)
 
+	if cfg.GRPC.LogLevelService && logLevels != nil {
+		registerLogLevelsGRPC(grpcSrv, logLevels)
+	}
+
 	httpMux := mux.NewRouter()
 	providers.Add(httpMux)
 
-	if starts, _ := modulesFilter(svc.opts.starts); len(starts) == 0 {
+	// see HTTPMiddlewares for the complete chain.
+	var muxChain []string
+
+	if cfg.Tracing.Enabled {
+		httpMux.Use(tr.httpMiddleware)
+		muxChain = append(muxChain, "tracing")
+	}
+
+	if cfg.Metrics.Enabled {
+		httpMux.Use(m.httpMiddleware)
+		muxChain = append(muxChain, "metrics")
+
+		if cfg.Metrics.Server == "http" {
+			httpMux.Handle(cfg.Metrics.Path, m.handler())
+		}
+	}
+
+	if httpRateLimiter != nil {
+		httpMux.Use(httpRateLimiter.httpMiddleware)
+		muxChain = append(muxChain, "rate-limit")
+	}
+
+	httpRecovery := newRecovery(l.Named("http"), "http", m, svc.opts.panicHook)
+
+	// recovery also wraps the whole server, see startHTTP.
+	httpMux.Use(httpRecovery.httpMiddleware)
+	muxChain = append(muxChain, "recovery")
+
+	for _, mw := range httpMiddlewares.sorted() {
+		httpMux.Use(mw.f)
+		muxChain = append(muxChain, mw.name)
+	}
+
+	if cfg.Health.Enabled && cfg.Health.HTTP {
+		httpMux.Handle(cfg.Health.LivenessPath, healthReg.handler(LivenessCheck))
+		httpMux.Handle(cfg.Health.ReadinessPath, healthReg.handler(ReadinessCheck))
+	}
+
+	notify.status("starting")
+
+	endPhase = m.phase("start")
+
+	if starts, _ := filter.filter(svc.opts.starts); len(starts) == 0 {
 		l.Debug("nothing to start")
 	} else {
 		l.Debug("starting up", "components", callbacksNames(starts))
@@ -343,26 +491,104 @@ func Start(opts ...OptFunc) (<-chan error, error) {
 		}
 	}
 
-	if svc.opts.grpc && cfg.GRPC.Enabled {
-		grpcAddr, err := startGRPC(l.Named("grpc"), grpcSrv, cfg.GRPC, errCh)
+	endPhase()
+
+	grpcEnabled := svc.opts.grpc && cfg.GRPC.Enabled
+	httpEnabled := svc.opts.http && cfg.HTTP.Enabled
+
+	var grpcHealth *health.Server
+
+	// after the start phase, so health servers registered by components are
+	// left as is, and before the in-process connection for transcoding
+	// starts serving.
+	if cfg.Health.Enabled && cfg.Health.GRPC {
+		if _, ok := grpcSrv.GetServiceInfo()[healthpb.Health_ServiceDesc.ServiceName]; ok {
+			l.Debug("service already registered", "service", healthpb.Health_ServiceDesc.ServiceName)
+		} else {
+			grpcHealth = health.NewServer()
+			grpcHealth.SetServingStatus("", healthpb.HealthCheckResponse_NOT_SERVING)
+			healthpb.RegisterHealthServer(grpcSrv, grpcHealth)
+		}
+	}
+
+	if grpcEnabled {
+		// before the in-process connection for transcoding starts serving.
+		registerGRPCDebugServices(l.Named("grpc"), grpcSrv, cfg.GRPC)
+	}
+
+	if httpEnabled && cfg.HTTP.GRPCTranscode {
+		l := l.Named("transcode")
+
+		conn, err := inProcessGRPCConn(l, grpcSrv, grpcTLSConfig, sd)
+		if err != nil {
+			return nil, fmt.Errorf("grpc in-process connection error: %w", err)
+		}
+
+		if err := transcode(l, httpMux, grpcSrv, conn, cfg.HTTP.GRPCTranscodePrefix); err != nil {
+			return nil, fmt.Errorf("grpc transcode error: %w", err)
+		}
+	}
+
+	if grpcEnabled && cfg.GRPC.ServeOnHTTP {
+		if !httpEnabled {
+			return nil, errors.New("serving GRPC on the HTTP server requires HTTP to be enabled")
+		}
+
+		l.Debug("GRPC will be served by the HTTP server")
+	} else if grpcEnabled {
+		liss, err := svc.listen(GRPCListener, listenAddrs(cfg.GRPC.Host, cfg.GRPC.Port, cfg.GRPC.Addrs), cfg.GRPC.Unix)
 		if err != nil {
-			return nil, fmt.Errorf("grpc start error: %w", err)
+			return nil, fmt.Errorf("grpc listen error: %w", err)
 		}
 
-		providers.Add(grpcAddr)
+		providers.Add(startGRPC(l.Named("grpc"), grpcSrv, cfg.GRPC, liss, sd, errCh))
 	} else {
 		l.Debug("not starting GRPC server")
 	}
 
-	if svc.opts.http && cfg.HTTP.Enabled {
-		if err := startHTTP(l.Named("http"), httpMux, cfg.HTTP, errCh); err != nil {
-			return nil, fmt.Errorf("http start error: %w", err)
+	if httpEnabled {
+		tlsConfig, err := startTLS(l.Named("http"), cfg.HTTP.TLS, []string{"h2", "http/1.1"}, sd)
+		if err != nil {
+			return nil, fmt.Errorf("http tls error: %w", err)
+		}
+
+		liss, err := svc.listen(HTTPListener, listenAddrs(cfg.HTTP.Host, cfg.HTTP.Port, cfg.HTTP.Addrs), cfg.HTTP.Unix)
+		if err != nil {
+			return nil, fmt.Errorf("http listen error: %w", err)
+		}
+
+		var h2grpc *grpc.Server
+		if grpcEnabled && cfg.GRPC.ServeOnHTTP {
+			h2grpc = grpcSrv
+		}
+
+		httpAddr := startHTTP(l.Named("http"), httpMux, muxChain, h2grpc, cfg.HTTP, withCORS, httpRecovery, tlsConfig, liss, sd, errCh)
+
+		providers.Add(httpAddr)
+
+		if h2grpc != nil {
+			providers.Add(GRPCAddr(httpAddr.Addr))
 		}
 	} else {
 		l.Debug("not starting HTTP server")
 	}
 
-	if readys, _ := modulesFilter(svc.opts.readys); len(readys) == 0 {
+	if cfg.Admin.Enabled || len(svc.listeners[AdminListener]) != 0 {
+		liss, err := svc.listen(AdminListener, listenAddrs(cfg.Admin.Host, cfg.Admin.Port, cfg.Admin.Addrs), cfg.Admin.Unix)
+		if err != nil {
+			return nil, fmt.Errorf("admin listen error: %w", err)
+		}
+
+		startAdmin(l.Named("admin"), "admin", adminRouter, liss, sd, errCh)
+	} else {
+		l.Debug("not starting admin server")
+	}
+
+	notify.status("readying")
+
+	endPhase = m.phase("ready")
+
+	if readys, _ := filter.filter(svc.opts.readys); len(readys) == 0 {
 		l.Debug("nothing to ready")
 	} else {
 		l.Debug("readying up", "components", callbacksNames(readys))
@@ -376,57 +602,180 @@ func Start(opts ...OptFunc) (<-chan error, error) {
 		}
 	}
 
+	endPhase()
+
+	healthReg.setReady(true)
+
+	healthCtx, cancelHealth := context.WithCancel(ctx)
+
+	if grpcHealth != nil {
+		services := make([]string, 0, len(grpcSrv.GetServiceInfo()))
+		for s := range grpcSrv.GetServiceInfo() {
+			services = append(services, s)
+		}
+
+		healthReg.updateGRPC(healthCtx, grpcHealth, services, cfg.Health.GRPCUpdateInterval)
+	}
+
+	notify.watchdog(healthCtx, func(ctx context.Context) error {
+		if err := healthReg.Live(ctx); err != nil {
+			return err
+		}
+
+		if f := svc.opts.watchdogCheck; f != nil {
+			return f(ctx)
+		}
+
+		return nil
+	})
+
+	// registered last so it will be called first.
+	sd.add("readiness", func(context.Context) error {
+		cancelHealth()
+
+		healthReg.setReady(false)
+		if grpcHealth != nil {
+			grpcHealth.Shutdown()
+		}
+
+		notify.stopping()
+		return nil
+	})
+
+	// only once nothing can fail anymore, so signals are never left captured.
+	if svc.opts.signalShutdown {
+		sd.watch(errCh)
+	}
+
+	// announced after signals are handled, so a supervisor stopping the
+	// service right when it is ready will not just kill it.
 	l.Info("ready!")
 
+	notify.status("ready")
+	notify.ready()
+
 	return errCh, nil
 }
 
-func startGRPC(l L.L, srv *grpc.Server, cfg grpcCfg, errCh chan<- error) (GRPCAddr, error) {
+func startGRPC(l L.L, srv *grpc.Server, cfg grpcCfg, liss []net.Listener, sd *shutdown, errCh chan<- error) GRPCAddr {
 	l.Debug("starting GRPC server", "cfg", cfg)
 
-	lis, err := net.Listen("tcp", fmt.Sprintf(":%d", cfg.Port))
-	if err != nil {
-		return nil, fmt.Errorf("grpc listen error: %w", err)
+	for _, lis := range liss {
+		lis := lis
+
+		go func() {
+			// Serve returns nil only if the server was stopped.
+			if err := srv.Serve(lis); err != nil {
+				l.Fatal("GRPC serve failed", "err", err)
+				errCh <- fmt.Errorf("GRPC serve error: %w", err)
+			}
+		}()
+
+		if cfg.Port == 0 {
+			l.Info("grpc started", "addr", lis.Addr())
+		} else {
+			l.Debug("grpc started", "addr", lis.Addr())
+		}
 	}
 
+	sd.add("grpc", func(ctx context.Context) error { return stopGRPC(ctx, srv) })
+
+	return GRPCAddr(liss[0].Addr())
+}
+
+// Stop the server gracefully, unless ctx is done first.
+func stopGRPC(ctx context.Context, srv *grpc.Server) error {
+	done := make(chan struct{})
+
 	go func() {
-		err := srv.Serve(lis)
-		l.Fatal("GRPC serve failed", "err", err)
-		errCh <- fmt.Errorf("GRPC serve error: %w", err)
+		srv.GracefulStop()
+		close(done)
 	}()
 
-	if cfg.Port == 0 {
-		l.Info("grpc started", "addr", lis.Addr())
-	} else {
-		l.Debug("grpc started", "addr", lis.Addr())
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		srv.Stop()
+		return ctx.Err()
 	}
-
-	return GRPCAddr(lis.Addr()), nil
 }
 
-func startHTTP(l L.L, r *mux.Router, cfg httpCfg, errCh chan<- error) error {
+// If grpcSrv is not nil, it is served on the same listeners. If rec is not
+// nil, it recovers panics in the whole chain.
+func startHTTP(l L.L, r *mux.Router, muxChain []string, grpcSrv *grpc.Server, cfg httpCfg, withCORS func(http.Handler) http.Handler, rec *recovery, tlsConfig *tls.Config, liss []net.Listener, sd *shutdown, errCh chan<- error) HTTPAddr {
 	l.Debug("starting HTTP server", "cfg", cfg)
 
-	h := handlers.CombinedLoggingHandler(
-		&Z.ApacheLogWriter{
-			Z:         Z.FromL(l),
-			InfoLevel: cfg.AccessLogInfoLevel,
-		},
-		r,
-	)
+	z := Z.FromL(l)
+
+	limited, limits := withHTTPLimits(r, cfg)
+
+	// the access log writer is per request so it includes the request ID.
+	h := withRequestID(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		handlers.CombinedLoggingHandler(
+			&Z.ApacheLogWriter{
+				Z:         z.With(requestIDLogField, RequestIDFromContext(req.Context())),
+				InfoLevel: cfg.AccessLogInfoLevel,
+			},
+			limited,
+		).ServeHTTP(w, req)
+	}))
+
+	chain := append(append([]string{"request-id", "access-log"}, limits...), "router")
 
-	if cfg.CORS {
-		h = cors.New(cors.Options{
-			AllowedOrigins:   cfg.CORSAllowedOrigins,
-			AllowCredentials: cfg.CORSAllowCredentials,
-		}).Handler(r)
+	if withCORS != nil {
+		h = withCORS(h)
+		chain = append([]string{"cors"}, chain...)
 	}
 
-	go func() {
-		err := http.ListenAndServe(fmt.Sprintf(":%d", cfg.Port), h)
-		l.Fatal("HTTP serve failed", "err", err)
-		errCh <- fmt.Errorf("HTTP serve error: %w", err)
-	}()
+	if grpcSrv != nil {
+		h = withGRPC(grpcSrv, h, tlsConfig == nil)
+		chain = append([]string{"grpc"}, chain...)
+	}
 
-	return nil
+	h = withPeerIdentity(h)
+	chain = append([]string{"peer-identity"}, chain...)
+
+	if rec != nil {
+		h = rec.httpMiddleware(h)
+		chain = append([]string{"recovery"}, chain...)
+	}
+
+	l.Debug("middlewares", "chain", append(chain, muxChain...))
+
+	srv := newHTTPServer(h, cfg)
+	srv.TLSConfig = tlsConfig
+
+	serve := srv.Serve
+	if tlsConfig != nil {
+		// certificates are provided by tlsConfig.
+		serve = func(lis net.Listener) error { return srv.ServeTLS(lis, "", "") }
+	}
+
+	for _, lis := range liss {
+		lis := lis
+
+		go func() {
+			if err := serve(lis); !errors.Is(err, http.ErrServerClosed) {
+				l.Fatal("HTTP serve failed", "err", err)
+				errCh <- fmt.Errorf("HTTP serve error: %w", err)
+			}
+		}()
+
+		l.Debug("http started", "addr", lis.Addr(), "tls", tlsConfig != nil)
+	}
+
+	sd.add("http", srv.Shutdown)
+
+	if grpcSrv != nil {
+		// added after the HTTP server so it is stopped first, otherwise
+		// Shutdown waits for open GRPC streams. GracefulStop is not supported
+		// for GRPC served by ServeHTTP.
+		sd.add("grpc", func(context.Context) error {
+			grpcSrv.Stop()
+			return nil
+		})
+	}
+
+	return HTTPAddr{Addr: liss[0].Addr()}
 }
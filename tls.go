@@ -0,0 +1,280 @@
+package svc
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+
+	"github.com/autokitteh/L"
+)
+
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// Mozilla's "intermediate" recommended cipher suites for TLS 1.2. TLS 1.3
+// cipher suites are not configurable.
+var intermediateCipherSuites = []uint16{
+	tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+	tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+	tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+	tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+	tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305_SHA256,
+	tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305_SHA256,
+}
+
+// Builds a server TLS configuration from cfg. Returns nil if TLS is not
+// enabled. The returned reloader must be watched in order for certificate
+// rotation to take effect.
+//
+// nextProtos must be specified here for ALPN, since when client CAs are
+// used the configuration is replaced per handshake, discarding any
+// protocols set by the server on a copy of it.
+func newTLSConfig(l L.L, cfg tlsCfg, nextProtos []string) (*tls.Config, *tlsReloader, error) {
+	if !cfg.Enabled() {
+		return nil, nil, nil
+	}
+
+	minVersion, ok := tlsVersions[cfg.MinVersion]
+	if !ok {
+		return nil, nil, fmt.Errorf("invalid min tls version %q", cfg.MinVersion)
+	}
+
+	tlsConfig := &tls.Config{MinVersion: minVersion, NextProtos: nextProtos}
+
+	switch cfg.CipherPolicy {
+	case "", "default":
+		// go defaults.
+	case "intermediate":
+		tlsConfig.CipherSuites = intermediateCipherSuites
+	case "modern":
+		tlsConfig.MinVersion = tls.VersionTLS13
+	default:
+		return nil, nil, fmt.Errorf("invalid cipher policy %q", cfg.CipherPolicy)
+	}
+
+	if cfg.RequireClientCert && cfg.ClientCAFile == "" {
+		return nil, nil, errors.New("client ca file must be specified if client cert is required")
+	}
+
+	r := &tlsReloader{l: l, cfg: cfg}
+
+	if err := r.load(); err != nil {
+		return nil, nil, err
+	}
+
+	tlsConfig.GetCertificate = r.getCertificate
+
+	if cfg.ClientCAFile != "" {
+		tlsConfig.ClientAuth = tls.VerifyClientCertIfGiven
+		if cfg.RequireClientCert {
+			tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+		}
+
+		// client CAs cannot be fetched dynamically, so the whole
+		// configuration is replaced on every handshake.
+		tlsConfig.GetConfigForClient = func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			c := tlsConfig.Clone()
+			c.GetConfigForClient = nil
+			c.ClientCAs = r.getClientCAs()
+			return c, nil
+		}
+	}
+
+	return tlsConfig, r, nil
+}
+
+// Returns a TLS configuration, or nil if TLS is not enabled, and keeps
+// reloading its certificates until shutdown.
+func startTLS(l L.L, cfg tlsCfg, nextProtos []string, sd *shutdown) (*tls.Config, error) {
+	tlsConfig, r, err := newTLSConfig(l, cfg, nextProtos)
+	if err != nil || tlsConfig == nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	r.watch(ctx)
+
+	sd.add("tls reloader", func(context.Context) error {
+		cancel()
+		return nil
+	})
+
+	return tlsConfig, nil
+}
+
+// Reloads certificates from disk when they change.
+type tlsReloader struct {
+	l   L.L
+	cfg tlsCfg
+
+	mu        sync.RWMutex
+	cert      *tls.Certificate
+	clientCAs *x509.CertPool
+	raw       [][]byte // cert, key, ca - as last loaded.
+}
+
+func (r *tlsReloader) getCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.cert, nil
+}
+
+func (r *tlsReloader) getClientCAs() *x509.CertPool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.clientCAs
+}
+
+// Load certificates from disk. Does nothing if unchanged since the last load.
+func (r *tlsReloader) load() error {
+	paths := []string{r.cfg.CertFile, r.cfg.KeyFile, r.cfg.ClientCAFile}
+	raw := make([][]byte, len(paths))
+
+	for i, path := range paths {
+		if path == "" {
+			continue
+		}
+
+		bs, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("read %q: %w", path, err)
+		}
+
+		raw[i] = bs
+	}
+
+	r.mu.RLock()
+	changed := false
+	for i := range raw {
+		if r.raw == nil || !bytes.Equal(raw[i], r.raw[i]) {
+			changed = true
+		}
+	}
+	r.mu.RUnlock()
+
+	if !changed {
+		return nil
+	}
+
+	cert, err := tls.X509KeyPair(raw[0], raw[1])
+	if err != nil {
+		return fmt.Errorf("load key pair: %w", err)
+	}
+
+	var clientCAs *x509.CertPool
+
+	if raw[2] != nil {
+		clientCAs = x509.NewCertPool()
+		if !clientCAs.AppendCertsFromPEM(raw[2]) {
+			return fmt.Errorf("no certificates found in %q", r.cfg.ClientCAFile)
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.raw != nil {
+		r.l.Info("certificates reloaded")
+	}
+
+	r.cert, r.clientCAs, r.raw = &cert, clientCAs, raw
+
+	return nil
+}
+
+// Poll for changes until ctx is done. On error, previously loaded
+// certificates are kept.
+func (r *tlsReloader) watch(ctx context.Context) {
+	if r.cfg.ReloadInterval <= 0 {
+		return
+	}
+
+	go func() {
+		t := time.NewTicker(r.cfg.ReloadInterval)
+		defer t.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-t.C:
+			}
+
+			if err := r.load(); err != nil {
+				r.l.Error("certificates reload failed", "err", err)
+			}
+		}
+	}()
+}
+
+// Identity of an authenticated peer, as presented in its verified TLS
+// client certificate.
+type PeerIdentity struct {
+	CommonName     string
+	DNSNames       []string
+	URIs           []string // for example, SPIFFE IDs.
+	EmailAddresses []string
+	Certificate    *x509.Certificate
+}
+
+func newPeerIdentity(cert *x509.Certificate) *PeerIdentity {
+	id := PeerIdentity{
+		CommonName:     cert.Subject.CommonName,
+		DNSNames:       cert.DNSNames,
+		EmailAddresses: cert.EmailAddresses,
+		Certificate:    cert,
+	}
+
+	for _, u := range cert.URIs {
+		id.URIs = append(id.URIs, u.String())
+	}
+
+	return &id
+}
+
+type peerIdentityCtxKey struct{}
+
+// Returns the identity of the peer if it presented a verified client
+// certificate. Works with contexts of both HTTP requests and GRPC calls
+// served by svc, including in GRPC interceptors.
+func PeerIdentityFromContext(ctx context.Context) (*PeerIdentity, bool) {
+	if id, ok := ctx.Value(peerIdentityCtxKey{}).(*PeerIdentity); ok {
+		return id, true
+	}
+
+	if p, ok := peer.FromContext(ctx); ok {
+		if info, ok := p.AuthInfo.(credentials.TLSInfo); ok && len(info.State.VerifiedChains) != 0 {
+			return newPeerIdentity(info.State.VerifiedChains[0][0]), true
+		}
+	}
+
+	return nil, false
+}
+
+func withPeerIdentity(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.TLS != nil && len(r.TLS.VerifiedChains) != 0 {
+			id := newPeerIdentity(r.TLS.VerifiedChains[0][0])
+			r = r.WithContext(context.WithValue(r.Context(), peerIdentityCtxKey{}, id))
+		}
+
+		h.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,231 @@
+//go:build unit
+
+package svc
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+
+	"github.com/autokitteh/L"
+)
+
+type testCert struct {
+	cert    *x509.Certificate
+	key     *ecdsa.PrivateKey
+	certPEM []byte
+	keyPEM  []byte
+}
+
+func (c *testCert) tlsCertificate(t *testing.T) tls.Certificate {
+	cert, err := tls.X509KeyPair(c.certPEM, c.keyPEM)
+	require.NoError(t, err)
+	return cert
+}
+
+// Generates a certificate signed by parent, or a self signed CA if parent is nil.
+func newTestCert(t *testing.T, cn string, parent *testCert) *testCert {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		DNSNames:     []string{cn},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+
+	signer, signerKey := tmpl, key
+	if parent == nil {
+		tmpl.IsCA, tmpl.BasicConstraintsValid = true, true
+		tmpl.KeyUsage |= x509.KeyUsageCertSign
+	} else {
+		signer, signerKey = parent.cert, parent.key
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, signer, &key.PublicKey, signerKey)
+	require.NoError(t, err)
+
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	return &testCert{
+		cert:    cert,
+		key:     key,
+		certPEM: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		keyPEM:  pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}),
+	}
+}
+
+// Writes server cert & key and client CA to dir.
+func writeTestCerts(t *testing.T, dir string, srv, ca *testCert) tlsCfg {
+	cfg := tlsCfg{
+		CertFile:     filepath.Join(dir, "tls.crt"),
+		KeyFile:      filepath.Join(dir, "tls.key"),
+		ClientCAFile: filepath.Join(dir, "ca.crt"),
+		MinVersion:   "1.2",
+	}
+
+	require.NoError(t, os.WriteFile(cfg.CertFile, srv.certPEM, 0o600))
+	require.NoError(t, os.WriteFile(cfg.KeyFile, srv.keyPEM, 0o600))
+	require.NoError(t, os.WriteFile(cfg.ClientCAFile, ca.certPEM, 0o600))
+
+	return cfg
+}
+
+func TestHTTPTLS(t *testing.T) {
+	ca := newTestCert(t, "ca", nil)
+	srvCert := newTestCert(t, "server", ca)
+	clientCert := newTestCert(t, "client", ca)
+
+	cfg := writeTestCerts(t, t.TempDir(), srvCert, ca)
+	cfg.RequireClientCert = true
+
+	tlsConfig, r, err := newTLSConfig(L.Nop, cfg, []string{"h2", "http/1.1"})
+	require.NoError(t, err)
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	srv := &http.Server{
+		TLSConfig: tlsConfig,
+		Handler: withPeerIdentity(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if id, ok := PeerIdentityFromContext(r.Context()); ok {
+				_, _ = w.Write([]byte(id.CommonName))
+			}
+		})),
+	}
+
+	go func() { _ = srv.ServeTLS(lis, "", "") }()
+
+	defer srv.Close()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+
+	get := func() (*http.Response, error) {
+		client := &http.Client{
+			Transport: &http.Transport{
+				TLSClientConfig: &tls.Config{
+					RootCAs:      roots,
+					Certificates: []tls.Certificate{clientCert.tlsCertificate(t)},
+				},
+			},
+		}
+
+		return client.Get("https://" + lis.Addr().String())
+	}
+
+	resp, err := get()
+	require.NoError(t, err)
+
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+
+	assert.Equal(t, "client", string(body))
+	assert.Equal(t, "server", resp.TLS.PeerCertificates[0].Subject.CommonName)
+
+	// rotate.
+	srvCert = newTestCert(t, "server2", ca)
+	require.NoError(t, os.WriteFile(cfg.CertFile, srvCert.certPEM, 0o600))
+	require.NoError(t, os.WriteFile(cfg.KeyFile, srvCert.keyPEM, 0o600))
+	require.NoError(t, r.load())
+
+	resp, err = get()
+	require.NoError(t, err)
+	resp.Body.Close()
+
+	assert.Equal(t, "server2", resp.TLS.PeerCertificates[0].Subject.CommonName)
+}
+
+func TestTLSConfigInvalid(t *testing.T) {
+	_, _, err := newTLSConfig(L.Nop, tlsCfg{CertFile: "x", MinVersion: "0.9"}, nil)
+	assert.Error(t, err)
+
+	_, _, err = newTLSConfig(L.Nop, tlsCfg{CertFile: "x", MinVersion: "1.2", RequireClientCert: true}, nil)
+	assert.Error(t, err)
+
+	tlsConfig, _, err := newTLSConfig(L.Nop, tlsCfg{}, nil)
+	assert.NoError(t, err)
+	assert.Nil(t, tlsConfig)
+}
+
+func TestGRPCTLS(t *testing.T) {
+	ca := newTestCert(t, "ca", nil)
+	srvCert := newTestCert(t, "server", ca)
+	clientCert := newTestCert(t, "client", ca)
+
+	cfg := writeTestCerts(t, t.TempDir(), srvCert, ca)
+	cfg.RequireClientCert = true
+
+	tlsConfig, _, err := newTLSConfig(L.Nop, cfg, []string{"h2"})
+	require.NoError(t, err)
+
+	var id *PeerIdentity
+
+	srv := grpc.NewServer(
+		grpc.Creds(credentials.NewTLS(tlsConfig)),
+		grpc.UnaryInterceptor(func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, h grpc.UnaryHandler) (interface{}, error) {
+			id, _ = PeerIdentityFromContext(ctx)
+			return h(ctx, req)
+		}),
+	)
+
+	healthpb.RegisterHealthServer(srv, health.NewServer())
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	go func() { _ = srv.Serve(lis) }()
+
+	defer srv.Stop()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+
+	conn, err := grpc.Dial(
+		lis.Addr().String(),
+		grpc.WithTransportCredentials(credentials.NewTLS(&tls.Config{
+			RootCAs:      roots,
+			Certificates: []tls.Certificate{clientCert.tlsCertificate(t)},
+		})),
+	)
+	require.NoError(t, err)
+
+	defer conn.Close()
+
+	_, err = healthpb.NewHealthClient(conn).Check(context.Background(), &healthpb.HealthCheckRequest{})
+	require.NoError(t, err)
+
+	if assert.NotNil(t, id) {
+		assert.Equal(t, "client", id.CommonName)
+		assert.Equal(t, []string{"client"}, id.DNSNames)
+	}
+}
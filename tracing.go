@@ -0,0 +1,165 @@
+package svc
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/gorilla/mux"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.10.0"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+)
+
+// Tracing is set up per svc instance and is not installed globally.
+// Components receive both trace.TracerProvider and a trace.Tracer named
+// after the service.
+type tracing struct {
+	tp         trace.TracerProvider
+	propagator propagation.TextMapPropagator
+
+	// nil if tracing is disabled.
+	shutdown func(context.Context) error
+}
+
+func newTracing(name string, cfg tracingCfg) (*tracing, error) {
+	t := tracing{
+		tp:         trace.NewNoopTracerProvider(),
+		propagator: propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}),
+	}
+
+	if !cfg.Enabled {
+		return &t, nil
+	}
+
+	exp, err := newTraceExporter(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("exporter: %w", err)
+	}
+
+	attrs := []attribute.KeyValue{semconv.ServiceNameKey.String(name)}
+
+	if v := GetVersion(); v != nil {
+		attrs = append(
+			attrs,
+			semconv.ServiceVersionKey.String(v.Version),
+			attribute.String("service.commit", v.Commit),
+			attribute.String("service.build_date", v.Date),
+		)
+	}
+
+	for k, v := range cfg.ResourceAttributes {
+		attrs = append(attrs, attribute.String(k, v))
+	}
+
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exp),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(cfg.SamplerRatio))),
+		sdktrace.WithResource(resource.NewWithAttributes(semconv.SchemaURL, attrs...)),
+	)
+
+	t.tp, t.shutdown = tp, tp.Shutdown
+
+	return &t, nil
+}
+
+func newTraceExporter(cfg tracingCfg) (sdktrace.SpanExporter, error) {
+	switch cfg.Exporter {
+	case "stdout":
+		return stdouttrace.New()
+	case "file":
+		f, err := os.OpenFile(cfg.File, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+		if err != nil {
+			return nil, err
+		}
+
+		exp, err := stdouttrace.New(stdouttrace.WithWriter(f))
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+
+		return &fileTraceExporter{SpanExporter: exp, f: f}, nil
+	case "otlp-grpc":
+		opts := []otlptracegrpc.Option{otlptracegrpc.WithHeaders(cfg.OTLPHeaders)}
+
+		if cfg.OTLPEndpoint != "" {
+			opts = append(opts, otlptracegrpc.WithEndpoint(cfg.OTLPEndpoint))
+		}
+
+		if cfg.OTLPInsecure {
+			opts = append(opts, otlptracegrpc.WithInsecure())
+		}
+
+		return otlptracegrpc.New(context.Background(), opts...)
+	case "otlp-http":
+		opts := []otlptracehttp.Option{otlptracehttp.WithHeaders(cfg.OTLPHeaders)}
+
+		if cfg.OTLPEndpoint != "" {
+			opts = append(opts, otlptracehttp.WithEndpoint(cfg.OTLPEndpoint))
+		}
+
+		if cfg.OTLPInsecure {
+			opts = append(opts, otlptracehttp.WithInsecure())
+		}
+
+		return otlptracehttp.New(context.Background(), opts...)
+	default:
+		return nil, fmt.Errorf("unknown exporter %q", cfg.Exporter)
+	}
+}
+
+// Closes the file once the exporter is shut down by the tracer provider.
+type fileTraceExporter struct {
+	sdktrace.SpanExporter
+	f *os.File
+}
+
+func (e *fileTraceExporter) Shutdown(ctx context.Context) error {
+	err := e.SpanExporter.Shutdown(ctx)
+
+	if cerr := e.f.Close(); err == nil {
+		err = cerr
+	}
+
+	return err
+}
+
+func (t *tracing) tracer(name string) trace.Tracer { return t.tp.Tracer(name) }
+
+func (t *tracing) unaryInterceptor() grpc.UnaryServerInterceptor {
+	return otelgrpc.UnaryServerInterceptor(otelgrpc.WithTracerProvider(t.tp), otelgrpc.WithPropagators(t.propagator))
+}
+
+func (t *tracing) streamInterceptor() grpc.StreamServerInterceptor {
+	return otelgrpc.StreamServerInterceptor(otelgrpc.WithTracerProvider(t.tp), otelgrpc.WithPropagators(t.propagator))
+}
+
+// Mux middleware, so spans are named after the matched route template.
+func (t *tracing) httpMiddleware(next http.Handler) http.Handler {
+	return otelhttp.NewHandler(
+		next,
+		"",
+		otelhttp.WithTracerProvider(t.tp),
+		otelhttp.WithPropagators(t.propagator),
+		otelhttp.WithSpanNameFormatter(func(_ string, r *http.Request) string {
+			if cr := mux.CurrentRoute(r); cr != nil {
+				if tmpl, err := cr.GetPathTemplate(); err == nil {
+					return r.Method + " " + tmpl
+				}
+			}
+
+			return r.Method
+		}),
+	)
+}
@@ -0,0 +1,105 @@
+//go:build unit
+
+package svc
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTracingDisabled(t *testing.T) {
+	tr, err := newTracing("test", tracingCfg{})
+	require.NoError(t, err)
+	assert.Nil(t, tr.shutdown)
+
+	_, span := tr.tracer("test").Start(context.Background(), "span")
+	assert.False(t, span.SpanContext().IsValid())
+}
+
+func TestTracingUnknownExporter(t *testing.T) {
+	_, err := newTracing("test", tracingCfg{Enabled: true, Exporter: "meow"})
+	assert.Error(t, err)
+}
+
+func TestTracingOTLPHTTP(t *testing.T) {
+	reqs := make(chan *http.Request, 10)
+
+	// collector stand-in.
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if len(body) != 0 {
+			reqs <- r
+		}
+	}))
+	defer collector.Close()
+
+	tr, err := newTracing("test", tracingCfg{
+		Enabled:      true,
+		Exporter:     "otlp-http",
+		OTLPEndpoint: strings.TrimPrefix(collector.URL, "http://"),
+		OTLPInsecure: true,
+		OTLPHeaders:  map[string]string{"X-Cats": "meow"},
+		SamplerRatio: 1,
+	})
+	require.NoError(t, err)
+
+	r := mux.NewRouter()
+	r.Use(tr.httpMiddleware)
+	r.HandleFunc("/cats/{name}", func(w http.ResponseWriter, r *http.Request) {
+		// incoming trace context is propagated.
+		_, span := tr.tracer("test").Start(r.Context(), "inner")
+		defer span.End()
+
+		assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", span.SpanContext().TraceID().String())
+	})
+
+	req := httptest.NewRequest("GET", "/cats/garfield", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	r.ServeHTTP(httptest.NewRecorder(), req)
+
+	require.NoError(t, tr.shutdown(context.Background()))
+
+	select {
+	case req := <-reqs:
+		assert.Equal(t, "/v1/traces", req.URL.Path)
+		assert.Equal(t, "meow", req.Header.Get("X-Cats"))
+	default:
+		t.Fatal("no spans exported")
+	}
+}
+
+func TestTracingFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "traces.json")
+
+	tr, err := newTracing("test", tracingCfg{Enabled: true, Exporter: "file", File: path, SamplerRatio: 1})
+	require.NoError(t, err)
+
+	_, span := tr.tracer("test").Start(context.Background(), "meow")
+	span.End()
+
+	require.NoError(t, tr.shutdown(context.Background()))
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `"Name":"meow"`)
+}
+
+func TestFileTraceExporterClose(t *testing.T) {
+	exp, err := newTraceExporter(tracingCfg{Exporter: "file", File: filepath.Join(t.TempDir(), "traces.json")})
+	require.NoError(t, err)
+
+	require.NoError(t, exp.Shutdown(context.Background()))
+
+	_, err = exp.(*fileTraceExporter).f.Write([]byte("x"))
+	assert.ErrorIs(t, err, os.ErrClosed)
+}
@@ -0,0 +1,267 @@
+package svc
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/dynamicpb"
+
+	"github.com/autokitteh/L"
+)
+
+const transcodeBufSize = 1024 * 1024
+
+// Remote address of in-process connections, as seen by the server.
+type inProcessAddr struct{}
+
+func (inProcessAddr) Network() string { return "in-process" }
+func (inProcessAddr) String() string  { return "in-process" }
+
+type inProcessListener struct{ *bufconn.Listener }
+
+func (l inProcessListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+
+	return inProcessConn{conn}, nil
+}
+
+type inProcessConn struct{ net.Conn }
+
+func (inProcessConn) RemoteAddr() net.Addr { return inProcessAddr{} }
+
+// Returns true if ctx is of a GRPC call made over an in-process connection,
+// which means it was transcoded from an HTTP request.
+func isInProcessGRPCCall(ctx context.Context) bool {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return false
+	}
+
+	_, ok = p.Addr.(inProcessAddr)
+	return ok
+}
+
+// Serve srv on an in-process connection, which is closed on shutdown. If
+// tlsConfig is not nil, srv expects TLS. Since this connection never leaves
+// the process, the server certificate is not verified.
+func inProcessGRPCConn(l L.L, srv *grpc.Server, tlsConfig *tls.Config, sd *shutdown) (*grpc.ClientConn, error) {
+	creds := insecure.NewCredentials()
+
+	if tlsConfig != nil {
+		if tlsConfig.ClientAuth == tls.RequireAndVerifyClientCert {
+			return nil, errors.New("in-process connections are not supported if client certificates are required")
+		}
+
+		creds = credentials.NewTLS(&tls.Config{InsecureSkipVerify: true})
+	}
+
+	lis := bufconn.Listen(transcodeBufSize)
+
+	go func() {
+		if err := srv.Serve(inProcessListener{lis}); err != nil {
+			l.Error("in-process GRPC serve failed", "err", err)
+		}
+	}()
+
+	conn, err := grpc.Dial(
+		"in-process",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(creds),
+	)
+	if err != nil {
+		lis.Close()
+		return nil, err
+	}
+
+	sd.add("in-process grpc conn", func(context.Context) error {
+		conn.Close()
+		return lis.Close()
+	})
+
+	return conn, nil
+}
+
+// Mount JSON/HTTP routes on r for all unary methods of all services
+// registered on srv, in the form of:
+//
+//	POST {prefix}/{package.Service}/{Method}
+//
+// Request bodies are JSON encoded request messages, and responses are JSON
+// encoded response messages. Errors are returned as JSON encoded
+// google.rpc.Status messages with an HTTP status matching their code.
+//
+// The Authorization header and all X-* headers are passed as metadata.
+//
+// Service descriptors must be registered in the global protobuf registry,
+// which is done by all generated code. GRPC tooling services, such as
+// reflection and channelz, are not transcoded.
+func transcode(l L.L, r *mux.Router, srv *grpc.Server, conn grpc.ClientConnInterface, prefix string) error {
+	prefix = strings.TrimSuffix(prefix, "/")
+
+	for sn := range srv.GetServiceInfo() {
+		if sn == reflectionServiceName || sn == channelzServiceName {
+			continue
+		}
+
+		d, err := protoregistry.GlobalFiles.FindDescriptorByName(protoreflect.FullName(sn))
+		if err != nil {
+			l.Warn("service descriptor not found, not transcoding", "service", sn, "err", err)
+			continue
+		}
+
+		sd, ok := d.(protoreflect.ServiceDescriptor)
+		if !ok {
+			return fmt.Errorf("%q is not a service", sn)
+		}
+
+		for i := 0; i < sd.Methods().Len(); i++ {
+			md := sd.Methods().Get(i)
+
+			if md.IsStreamingClient() || md.IsStreamingServer() {
+				continue
+			}
+
+			path := fmt.Sprintf("%s/%s/%s", prefix, sn, md.Name())
+
+			l.Debug("transcoding", "path", path)
+
+			r.Handle(path, &transcoder{conn: conn, md: md}).Methods(http.MethodPost)
+		}
+	}
+
+	return nil
+}
+
+type transcoder struct {
+	conn grpc.ClientConnInterface
+	md   protoreflect.MethodDescriptor
+}
+
+func newMessage(d protoreflect.MessageDescriptor) proto.Message {
+	if mt, err := protoregistry.GlobalTypes.FindMessageByName(d.FullName()); err == nil {
+		return mt.New().Interface()
+	}
+
+	return dynamicpb.NewMessage(d)
+}
+
+func (t *transcoder) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	req, resp := newMessage(t.md.Input()), newMessage(t.md.Output())
+
+	bs, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeTranscodeError(w, status.Errorf(codes.InvalidArgument, "read body: %v", err))
+		return
+	}
+
+	if len(bs) != 0 {
+		if err := protojson.Unmarshal(bs, req); err != nil {
+			writeTranscodeError(w, status.Errorf(codes.InvalidArgument, "invalid request: %v", err))
+			return
+		}
+	}
+
+	md := metadata.MD{}
+
+	for k, vs := range r.Header {
+		if k == "Authorization" || strings.HasPrefix(k, "X-") {
+			md.Append(strings.ToLower(k), vs...)
+		}
+	}
+
+	ctx := metadata.NewOutgoingContext(r.Context(), md)
+
+	method := fmt.Sprintf("/%s/%s", t.md.Parent().FullName(), t.md.Name())
+
+	if err := t.conn.Invoke(ctx, method, req, resp); err != nil {
+		writeTranscodeError(w, err)
+		return
+	}
+
+	if bs, err = protojson.Marshal(resp); err != nil {
+		writeTranscodeError(w, status.Errorf(codes.Internal, "marshal response: %v", err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(bs)
+}
+
+func writeTranscodeError(w http.ResponseWriter, err error) {
+	st := status.Convert(err)
+
+	bs, merr := protojson.Marshal(st.Proto())
+	if merr != nil {
+		// details might not be resolvable.
+		bs, _ = protojson.Marshal(status.New(st.Code(), st.Message()).Proto())
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(HTTPStatusFromCode(st.Code()))
+	_, _ = w.Write(bs)
+}
+
+// Maps a GRPC status code to an HTTP status, same as grpc-gateway does.
+func HTTPStatusFromCode(code codes.Code) int {
+	switch code {
+	case codes.OK:
+		return http.StatusOK
+	case codes.Canceled:
+		return 499 // client closed request.
+	case codes.Unknown:
+		return http.StatusInternalServerError
+	case codes.InvalidArgument:
+		return http.StatusBadRequest
+	case codes.DeadlineExceeded:
+		return http.StatusGatewayTimeout
+	case codes.NotFound:
+		return http.StatusNotFound
+	case codes.AlreadyExists:
+		return http.StatusConflict
+	case codes.PermissionDenied:
+		return http.StatusForbidden
+	case codes.Unauthenticated:
+		return http.StatusUnauthorized
+	case codes.ResourceExhausted:
+		return http.StatusTooManyRequests
+	case codes.FailedPrecondition:
+		return http.StatusBadRequest
+	case codes.Aborted:
+		return http.StatusConflict
+	case codes.OutOfRange:
+		return http.StatusBadRequest
+	case codes.Unimplemented:
+		return http.StatusNotImplemented
+	case codes.Internal:
+		return http.StatusInternalServerError
+	case codes.Unavailable:
+		return http.StatusServiceUnavailable
+	case codes.DataLoss:
+		return http.StatusInternalServerError
+	default:
+		return http.StatusInternalServerError
+	}
+}
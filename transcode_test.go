@@ -0,0 +1,64 @@
+//go:build unit
+
+package svc
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+
+	"github.com/autokitteh/L"
+)
+
+func TestTranscode(t *testing.T) {
+	srv := grpc.NewServer()
+
+	hs := health.NewServer()
+	hs.SetServingStatus("meow", healthpb.HealthCheckResponse_NOT_SERVING)
+	healthpb.RegisterHealthServer(srv, hs)
+
+	sd := newShutdown(L.Nop, 0)
+	defer sd.run()
+
+	conn, err := inProcessGRPCConn(L.Nop, srv, nil, sd)
+	require.NoError(t, err)
+
+	r := mux.NewRouter()
+	require.NoError(t, transcode(L.Nop, r, srv, conn, "/api/"))
+
+	post := func(path, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, path, strings.NewReader(body)))
+		return w
+	}
+
+	w := post("/api/grpc.health.v1.Health/Check", `{"service": "meow"}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"status": "NOT_SERVING"}`, w.Body.String())
+
+	w = post("/api/grpc.health.v1.Health/Check", `{"service": "woof"}`)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Contains(t, w.Body.String(), `"code":5`)
+
+	w = post("/api/grpc.health.v1.Health/Check", `{"nope": 1}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	// streaming methods are not transcoded.
+	w = post("/api/grpc.health.v1.Health/Watch", `{}`)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestHTTPStatusFromCode(t *testing.T) {
+	assert.Equal(t, http.StatusOK, HTTPStatusFromCode(codes.OK))
+	assert.Equal(t, http.StatusTooManyRequests, HTTPStatusFromCode(codes.ResourceExhausted))
+	assert.Equal(t, http.StatusInternalServerError, HTTPStatusFromCode(codes.Code(1234)))
+}